@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sneakybueno/fli/fuego"
+	"github.com/sneakybueno/fli/shell"
+)
+
+// auditHandler prints the entries of the audit log, newest last
+func (fli *Fli) auditHandler(args []string, s *shell.Shell) (string, error) {
+	client := fli.fStore.Client()
+	if client.Audit == nil {
+		return "", fmt.Errorf("%s: auditing is disabled", args[0])
+	}
+
+	var output bytes.Buffer
+	var filter fuego.AuditFilter
+	var since time.Duration
+	var all bool
+	var n int
+
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.SetOutput(&output)
+	flags.StringVar(&filter.Method, "method", "", "Only show requests with this http method")
+	flags.StringVar(&filter.Path, "path", "", "Only show requests to this path or its children")
+	flags.StringVar(&filter.User, "user", "", "Only show requests by this OS user or service account")
+	flags.DurationVar(&since, "since", 0, "Only show requests made within this duration, e.g. 24h")
+	flags.BoolVar(&all, "all", false, "Show requests to every database, not only the current one")
+	flags.IntVar(&n, "n", 20, "Number of entries to show, 0 shows everything")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return "", fmt.Errorf("%s", strings.TrimSpace(output.String()))
+	}
+
+	if filter.Path != "" {
+		filter.Path = fli.fStore.BuildWorkingDirectoryPath(filter.Path)
+	}
+
+	if since > 0 {
+		filter.Since = time.Now().Add(-since)
+	}
+
+	if !all {
+		filter.Database = client.FirebaseURL
+	}
+
+	entries, err := client.Audit.Entries(filter)
+	if err != nil {
+		return "", err
+	}
+
+	if n > 0 && len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+
+	lines := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		hash := entry.PayloadHash
+		if len(hash) > 12 {
+			hash = hash[:12]
+		}
+
+		line := fmt.Sprintf("%s  %-8s %-24s %-6s ~/%s  %d  %s",
+			entry.Time.Local().Format("2006-01-02 15:04:05"), entry.User, entry.Account,
+			entry.Method, entry.Path, entry.Status, hash)
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sneakybueno/fli/fuego"
+	"github.com/sneakybueno/fli/shell"
+)
+
+// commands returns fli's commands. The same commands are registered
+// with the interactive shell and run as subcommands, e.g. fli ls users.
+func (fli *Fli) commands() []*shell.Command {
+	path := fli.pathCompleter
+	key := fli.keyCompleter
+
+	return []*shell.Command{
+		{
+			Name:    "hello",
+			Handler: fli.helloHandler,
+			Help:    "Say hello",
+		},
+		{
+			Name:    "audit",
+			Handler: fli.auditHandler,
+			Usage:   "[-method m] [-path p] [-user u] [-since d] [-all] [-n n]",
+			Help:    "Show the audit log of write requests",
+		},
+		{
+			Name:      "bookmark",
+			Handler:   fli.bookmarkHandler,
+			Usage:     "add [name] [path] | ls | rm [name]",
+			Help:      "Manage bookmarks, used as @name in paths",
+			Completer: shell.Positional(shell.Words("add", "ls", "rm"), nil, path),
+		},
+		{
+			Name:      "cat",
+			Handler:   fli.catHandler,
+			Usage:     "[-p] [path]",
+			Help:      "Print the data at path as indented JSON, -p includes priorities",
+			Completer: shell.Positional(path),
+		},
+		{
+			Name:      "cd",
+			Handler:   fli.cdHandler,
+			Usage:     "[path]",
+			Help:      "Change the working directory, - goes back",
+			Completer: shell.Positional(path),
+		},
+		{
+			Name:    "dirs",
+			Handler: fli.dirsHandler,
+			Help:    "Show the directory stack",
+		},
+		{
+			Name:      "dryrun",
+			Handler:   fli.dryRunHandler,
+			Usage:     "[on|off]",
+			Help:      "Log write requests instead of sending them",
+			Completer: shell.Positional(shell.Words("on", "off")),
+		},
+		{
+			Name:      "export",
+			Handler:   fli.exportHandler,
+			Usage:     "[path] [file]",
+			Help:      "Print the data at path in export format, or save it to file",
+			Completer: shell.Positional(path),
+		},
+		{
+			Name:      "find",
+			Handler:   fli.searchHandler,
+			Usage:     "[path] [key] [value]",
+			Help:      "Find the children of path whose key equals value",
+			Completer: shell.Positional(path, key),
+		},
+		{
+			Name:      "get",
+			Handler:   fli.getHandler,
+			Usage:     "[path]",
+			Help:      "Print the data at path as compact JSON",
+			Completer: shell.Positional(path),
+		},
+		{
+			Name:      "history",
+			Handler:   fli.historyHandler,
+			Usage:     "[--writes]",
+			Help:      "Show the command history, or the writes that can be undone",
+			Completer: shell.Positional(shell.Words("--writes")),
+		},
+		{
+			Name:      "incr",
+			Handler:   fli.incrHandler,
+			Usage:     "[path] [delta]",
+			Help:      "Atomically add delta to the number at path",
+			Completer: shell.Positional(path),
+		},
+		{
+			Name:      "ls",
+			Handler:   fli.lsHandler,
+			Usage:     "[-p] [path]",
+			Help:      "List the keys at path, -p includes priorities",
+			Completer: shell.Positional(path),
+		},
+		{
+			Name:      "locate",
+			Handler:   fli.indexedSearchHandler,
+			Usage:     "[--rules|--fix-rules] [path] [key] [value]",
+			Help:      "Find the children of path whose key equals value using an index",
+			Completer: shell.Positional(path, key),
+		},
+		{
+			Name:      "mv",
+			Handler:   fli.mvHandler,
+			Usage:     "[src] [dst]",
+			Help:      "Move the data at src to dst",
+			Completer: shell.Positional(path, path),
+		},
+		{
+			Name:      "open",
+			Handler:   fli.openHandler,
+			Usage:     "[path]",
+			Help:      "Open path in the default browser",
+			Completer: shell.Positional(path),
+		},
+		{
+			Name:    "popd",
+			Handler: fli.popdHandler,
+			Help:    "Return to the directory on top of the directory stack",
+		},
+		{
+			Name:      "priority",
+			Handler:   fli.priorityHandler,
+			Usage:     "[path] [priority]",
+			Help:      "Show or set the priority of the data at path",
+			Completer: shell.Positional(path),
+		},
+		{
+			Name:      "pushd",
+			Handler:   fli.pushdHandler,
+			Usage:     "[path]",
+			Help:      "Change the working directory, saving it on the directory stack",
+			Completer: shell.Positional(path),
+		},
+		{
+			Name:    "pwd",
+			Handler: fli.pwdHandler,
+			Help:    "Print the working directory",
+		},
+		{
+			Name:      "rm",
+			Handler:   fli.rmHandler,
+			Usage:     "[path]",
+			Help:      "Delete the data at path",
+			Completer: shell.Positional(path),
+		},
+		{
+			Name:      "rules",
+			Handler:   fli.rulesHandler,
+			Usage:     "get [file] | set [file] | edit | diff [file]",
+			Help:      "Show, diff, upload or edit the security rules",
+			Completer: shell.Positional(shell.Words("get", "set", "edit", "diff")),
+		},
+		{
+			Name:      "set",
+			Handler:   fli.setHandler,
+			Usage:     "[path] [value]",
+			Help:      "Replace the data at path with value, or with the piped data",
+			Completer: shell.Positional(path),
+		},
+		{
+			Name:    "su",
+			Handler: fli.suHandler,
+			Usage:   "[uid [claims file]] | -",
+			Help:    "Make requests as uid so security rules apply, - stops",
+		},
+		{
+			Name:    "undo",
+			Handler: fli.undoHandler,
+			Usage:   "[n]",
+			Help:    "Undo the last n writes",
+		},
+	}
+}
+
+// getHandler prints the data at a path as compact JSON,
+// which is easier to use from scripts than cat
+func (fli *Fli) getHandler(args []string, s *shell.Shell) (string, error) {
+	if len(args) < 2 {
+		return "", fmt.Errorf("%s: [path]", args[0])
+	}
+
+	p, err := fli.singlePath(args[1])
+	if err != nil {
+		return "", err
+	}
+
+	data, err := fli.fStore.Get(p, false)
+	if err != nil {
+		return "", err
+	}
+
+	s.SetValue(data)
+	return fuego.JSONString(data), nil
+}
+
+// runCommand runs args as a single command and returns the exit code:
+// 0 on success, 1 when the command fails, 2 when there's no such command
+func (fli *Fli) runCommand(args []string) int {
+	s := shell.New(fli.fStore.Prompt())
+	s.Register(fli.commands()...)
+
+	if _, err := s.FindCommand(args[0]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, "run fli help for a list of commands")
+		return 2
+	}
+
+	if _, err := s.Run(args); err != nil {
+		return 1
+	}
+
+	return 0
+}
+
+// usage prints fli's flags and commands
+func (fli *Fli) usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: fli [flags] [command [args...]]")
+	fmt.Fprintln(w, "\nRuns command and exits, or starts the shell without one.")
+	fmt.Fprintln(w, "\nflags:")
+	flag.CommandLine.SetOutput(w)
+	flag.PrintDefaults()
+
+	s := shell.New("")
+	s.Register(fli.commands()...)
+	fmt.Fprintf(w, "\ncommands:\n%s\n", s.Help())
+}
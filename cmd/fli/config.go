@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config is fli's config file, stored as JSON in
+// the user's config directory (see configDir)
+type Config struct {
+	Profiles map[string]Profile `json:"profiles"`
+}
+
+// Profile holds the settings for a single database.
+// Command line flags take precedence over profile values.
+type Profile struct {
+	Host   string `json:"host"`
+	Config string `json:"config"`
+
+	// ReadOnly rejects every write request
+	ReadOnly bool `json:"readOnly"`
+
+	// Protected paths require typed confirmation before
+	// they, or anything under them, are written to
+	Protected []string `json:"protected"`
+
+	// CdCheck checks that cd targets exist: off, warn or strict
+	CdCheck string `json:"cdCheck"`
+
+	// HistorySize is the number of commands kept in the history
+	HistorySize int `json:"historySize"`
+	// SharedHistory keeps a single history for every database
+	// instead of one per database
+	SharedHistory bool `json:"sharedHistory"`
+}
+
+// configDir returns the directory fli keeps its files in
+func configDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "fli"), nil
+}
+
+// loadConfig reads config.json from fli's config directory.
+// A missing config file is not an error.
+func loadConfig() (*Config, error) {
+	config := &Config{Profiles: map[string]Profile{}}
+
+	dir, err := configDir()
+	if err != nil {
+		return config, nil
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "config.json"))
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(b, config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// databaseFile returns the path of a file in fli's config directory
+// that belongs to a single database, e.g. journal/my-db.firebaseio.com.jsonl.
+// The parent directory is created if needed.
+func databaseFile(kind string, firebaseURL string, ext string) (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+
+	name := firebaseURL
+	if u, err := url.Parse(firebaseURL); err == nil && u.Host != "" {
+		name = u.Host + u.Path
+	}
+	name = strings.Trim(name, "/")
+	name = strings.NewReplacer("/", "_", ":", "_").Replace(name)
+
+	dir = filepath.Join(dir, kind)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, name+ext), nil
+}
+
+// historyFile returns the file the shell's history is kept in,
+// shared by every database or specific to firebaseURL
+func historyFile(firebaseURL string, shared bool) (string, error) {
+	if !shared {
+		return databaseFile("history", firebaseURL, "")
+	}
+
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "shell_history"), nil
+}
+
+// splitPaths splits a comma separated list of database paths
+func splitPaths(list string) []string {
+	var paths []string
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+
+	return paths
+}
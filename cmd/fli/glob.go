@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sneakybueno/fli/shell"
+)
+
+// forEachPath expands the wildcards in p and calls fn with the
+// absolute path of every match. When there's more than one match
+// each output is headed by its path, like ls with several directories.
+// Every match is visited, the first error is returned.
+func (fli *Fli) forEachPath(p string, fn func(p string) (string, error)) (string, error) {
+	paths, err := fli.fStore.Expand(p)
+	if err != nil {
+		return "", err
+	}
+
+	return visitPaths(paths, fn)
+}
+
+// forEachWrite is forEachPath for commands that write to the matches.
+// Protected matches are confirmed before anything is written, so
+// cancelling a confirmation leaves every match as is.
+func (fli *Fli) forEachWrite(s *shell.Shell, p string, fn func(p string) (string, error)) (string, error) {
+	paths, err := fli.fStore.Expand(p)
+	if err != nil {
+		return "", err
+	}
+
+	absolute := make([]string, len(paths))
+	for i, match := range paths {
+		absolute[i] = "~/" + match
+	}
+
+	if err := fli.confirmWrite(s, absolute...); err != nil {
+		return "", err
+	}
+
+	return visitPaths(paths, fn)
+}
+
+// visitPaths calls fn with the absolute path of every match,
+// see forEachPath
+func visitPaths(paths []string, fn func(p string) (string, error)) (string, error) {
+	if len(paths) == 1 {
+		return fn("~/" + paths[0])
+	}
+
+	var firstErr error
+	outputs := make([]string, 0, len(paths))
+
+	for _, match := range paths {
+		out, err := fn("~/" + match)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("~/%s: %s", match, err)
+			}
+			out = fmt.Sprintf("error: %s", err)
+		}
+
+		if out == "" {
+			continue
+		}
+
+		outputs = append(outputs, fmt.Sprintf("~/%s:\n%s", match, out))
+	}
+
+	return strings.Join(outputs, "\n\n"), firstErr
+}
+
+// singlePath expands the wildcards in p and returns the
+// absolute path of the only match
+func (fli *Fli) singlePath(p string) (string, error) {
+	paths, err := fli.fStore.Expand(p)
+	if err != nil {
+		return "", err
+	}
+
+	if len(paths) > 1 {
+		return "", fmt.Errorf("%s matches %d paths, expected 1", p, len(paths))
+	}
+
+	return "~/" + paths[0], nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sneakybueno/fli/fuego"
+	"github.com/sneakybueno/fli/shell"
+)
+
+func TestForEachWriteConfirmsFirst(t *testing.T) {
+	var writes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			writes = append(writes, r.Method+" "+r.URL.Path)
+		}
+
+		if r.URL.Path == "/users.json" {
+			fmt.Fprint(w, `{"a": true, "b": true, "c": true}`)
+			return
+		}
+		fmt.Fprint(w, `null`)
+	}))
+	defer server.Close()
+
+	// the second confirmation is cancelled
+	var asked []string
+	fli := &Fli{
+		fStore:    fuego.NewFStoreWithClient(&fuego.FClient{FirebaseURL: server.URL + "/"}),
+		protected: []string{"~/users"},
+		readLine: func(s *shell.Shell, message string) (string, error) {
+			asked = append(asked, message)
+			if len(asked) == 2 {
+				return "", fmt.Errorf("shell: cancelled")
+			}
+
+			return "~/users/a", nil
+		},
+	}
+
+	if _, err := fli.rmHandler([]string{"rm", "users/*"}, shell.New("")); err == nil {
+		t.Errorf("Expected the cancellation to be returned")
+	}
+
+	if len(asked) != 2 {
+		t.Errorf("Expected to stop asking after the cancellation, asked %v", asked)
+	}
+
+	if len(writes) != 0 {
+		t.Errorf("Expected no writes, got %v", writes)
+	}
+}
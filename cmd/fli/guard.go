@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sneakybueno/fli/fuego"
+	"github.com/sneakybueno/fli/shell"
+)
+
+// cleanProtectedPath expands a leading @bookmark in p and normalizes it
+// so it can be compared against paths built by the FStore. p is always
+// absolute, e.g. users, /users and ~/users/ are the same path.
+func cleanProtectedPath(bookmarks *fuego.Bookmarks, p string) string {
+	return strings.Join(fuego.NormalizePath(nil, bookmarks.Expand(p)), "/")
+}
+
+// isProtected returns true when writing to p would touch a protected
+// path, either because p is under a protected path or because
+// p contains one (e.g. rm users when users/admins is protected).
+// protected paths are cleaned with cleanProtectedPath.
+func isProtected(protected []string, p string) bool {
+	for _, prefix := range protected {
+		switch {
+		case p == "" || prefix == "" || p == prefix:
+			return true
+		case strings.HasPrefix(p, prefix+"/"):
+			return true
+		case strings.HasPrefix(prefix, p+"/"):
+			return true
+		}
+	}
+
+	return false
+}
+
+// confirmWrite asks the user to type the full path of every protected
+// path that is about to be written to. Paths are relative to the
+// working directory.
+func (fli *Fli) confirmWrite(s *shell.Shell, paths ...string) error {
+	bookmarks := fli.fStore.Bookmarks
+
+	protected := make([]string, len(fli.protected))
+	for i, p := range fli.protected {
+		protected[i] = cleanProtectedPath(bookmarks, p)
+	}
+
+	for _, p := range paths {
+		p = fli.fStore.BuildWorkingDirectoryPath(p)
+		if !isProtected(protected, p) {
+			continue
+		}
+
+		message := fmt.Sprintf("~/%s is protected, type the path to confirm: ", p)
+		input, err := fli.readConfirmation(s, message)
+		if err != nil {
+			return err
+		}
+
+		if cleanProtectedPath(bookmarks, input) != p {
+			return fmt.Errorf("confirmation failed, ~/%s was not changed", p)
+		}
+	}
+
+	return nil
+}
+
+// readConfirmation prints message and reads the user's answer
+func (fli *Fli) readConfirmation(s *shell.Shell, message string) (string, error) {
+	if fli.readLine != nil {
+		return fli.readLine(s, message)
+	}
+
+	return s.ReadLine(message)
+}
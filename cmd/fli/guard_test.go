@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sneakybueno/fli/fuego"
+	"github.com/sneakybueno/fli/shell"
+)
+
+func TestIsProtected(t *testing.T) {
+	protected := []string{"users", "config/admins"}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"users", true},
+		{"users/bueno", true},
+		{"users2", false},
+		{"users2/bueno", false},
+		{"config", true},
+		{"config/admins/bueno", true},
+		{"config/adminsX", false},
+		{"config/flags", false},
+		{"", true},
+		{"orders", false},
+	}
+
+	for _, test := range tests {
+		if actual := isProtected(protected, test.path); actual != test.expected {
+			t.Errorf("isProtected(%q): expected %t, got %t", test.path, test.expected, actual)
+		}
+	}
+}
+
+func TestCleanProtectedPath(t *testing.T) {
+	bookmarks, _ := fuego.OpenBookmarks("")
+	bookmarks.Add("admins", "config/admins")
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"users", "users"},
+		{"~/users/", "users"},
+		{"/users//bueno", "users/bueno"},
+		{"~", ""},
+		{"@admins", "config/admins"},
+		{"@admins/bueno", "config/admins/bueno"},
+		{"@missing", "@missing"},
+	}
+
+	for _, test := range tests {
+		if actual := cleanProtectedPath(bookmarks, test.path); actual != test.expected {
+			t.Errorf("cleanProtectedPath(%q): expected %q, got %q", test.path, test.expected, actual)
+		}
+	}
+}
+
+func TestConfirmWrite(t *testing.T) {
+	bookmarks, _ := fuego.OpenBookmarks("")
+	bookmarks.Add("admins", "config/admins")
+
+	fStore := &fuego.FStore{FirebaseURL: "https://go-fli.firebaseio.com/"}
+	fStore.Bookmarks = bookmarks
+
+	tests := []struct {
+		path    string
+		answer  string
+		asked   bool
+		success bool
+	}{
+		{"orders/a", "", false, true},
+		{"config/admins/bueno", "~/config/admins/bueno", true, true},
+		{"config/admins/bueno", "@admins/bueno", true, true},
+		{"config/admins/bueno", "config/admins", true, false},
+		{"config", "config/admins", true, false},
+	}
+
+	for _, test := range tests {
+		asked := false
+		fli := &Fli{
+			fStore:    fStore,
+			protected: []string{"@admins"},
+			readLine: func(s *shell.Shell, message string) (string, error) {
+				asked = true
+				return test.answer, nil
+			},
+		}
+
+		err := fli.confirmWrite(shell.New(""), "~/"+test.path)
+		if asked != test.asked {
+			t.Errorf("%s: expected asked to be %t", test.path, test.asked)
+		}
+
+		if (err == nil) != test.success {
+			t.Errorf("%s answered with %s: expected success %t, got %v", test.path, test.answer, test.success, err)
+		}
+	}
+
+	// cancelling fails the confirmation
+	fli := &Fli{
+		fStore:    fStore,
+		protected: []string{"config"},
+		readLine: func(s *shell.Shell, message string) (string, error) {
+			return "", fmt.Errorf("shell: cancelled")
+		},
+	}
+
+	if err := fli.confirmWrite(shell.New(""), "~/config"); err == nil {
+		t.Errorf("Expected the cancellation to be returned")
+	}
+}
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/skratchdot/open-golang/open"
 	"github.com/sneakybueno/fli/fuego"
@@ -12,54 +18,239 @@ import (
 
 type Fli struct {
 	fStore *fuego.FStore
+
+	// protected paths need typed confirmation before writes
+	protected []string
+
+	// readLine reads confirmations, nil reads them from the shell
+	readLine func(s *shell.Shell, message string) (string, error)
 }
 
 func main() {
 	var firebaseURL string
 	var serviceAccountPath string
-
+	var dryRun bool
+	var readOnly bool
+	var protected string
+	var profileName string
+	var cdCheck string
+	var historySize int
+	var command string
+	var scriptFile string
+	var continueOnError bool
+
+	// fStore and protected are set once connected, the commands
+	// are needed before that to print the usage
+	fli := &Fli{}
+
+	flag.Usage = func() { fli.usage(flag.CommandLine.Output()) }
 	flag.StringVar(&firebaseURL, "host", "", "Firebase database URL (Required)")
 	flag.StringVar(&serviceAccountPath, "config", "", "Path to service account file (Required)")
+	flag.BoolVar(&dryRun, "dry-run", false, "Log mutating requests instead of sending them")
+	flag.BoolVar(&readOnly, "read-only", false, "Reject every write request")
+	flag.StringVar(&protected, "protect", "", "Comma separated paths that require confirmation before writes")
+	flag.StringVar(&profileName, "profile", "", "Name of a profile in fli's config file")
+	flag.StringVar(&cdCheck, "cd-check", "", "Check that cd targets exist: off, warn or strict")
+	flag.IntVar(&historySize, "history-size", 0, "Number of commands kept in the history")
+	flag.StringVar(&command, "c", "", "Run the given commands, one per line, and exit")
+	flag.StringVar(&scriptFile, "f", "", "Run the commands in the given file, one per line, and exit")
+	flag.BoolVar(&continueOnError, "continue-on-error", false, "Keep running commands after one fails when not interactive")
 	flag.Parse()
 
-	if firebaseURL == "" || serviceAccountPath == "" {
-		flag.PrintDefaults()
+	// fli [flags] [command [args...]] runs a single command
+	args := flag.Args()
+	if len(args) > 0 && (command != "" || scriptFile != "") {
+		fmt.Fprintln(os.Stderr, "-c and -f can't be used with a command")
+		os.Exit(2)
+	}
+
+	if len(args) > 0 && args[0] == "help" {
+		s := shell.New("")
+		s.Register(fli.commands()...)
+		if _, err := s.Run(args); err != nil {
+			os.Exit(2)
+		}
+		os.Exit(0)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var profile Profile
+	if profileName != "" {
+		var ok bool
+		profile, ok = config.Profiles[profileName]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "profile not found: %s\n", profileName)
+			os.Exit(1)
+		}
+	}
+
+	if firebaseURL == "" {
+		firebaseURL = profile.Host
+	}
+
+	if serviceAccountPath == "" {
+		serviceAccountPath = profile.Config
+	}
+
+	if cdCheck == "" {
+		cdCheck = profile.CdCheck
+	}
+
+	if historySize == 0 {
+		historySize = profile.HistorySize
+	}
+
+	cdCheckMode, err := fuego.ParseCdCheckMode(cdCheck)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
+	if firebaseURL == "" || serviceAccountPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
 	fStore, err := fuego.NewFStore(firebaseURL, serviceAccountPath)
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Time to fli @ %s\n", fStore.FirebaseURL)
+	fStore.Client().DryRun = dryRun
+	fStore.CdCheck = cdCheckMode
 
-	s, err := shell.Init(fStore.Prompt())
+	journalFile, err := databaseFile("journal", firebaseURL, ".jsonl")
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	fli := &Fli{fStore: fStore}
+	fStore.Journal, err = fuego.OpenJournal(journalFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	// Register command handlers
-	s.AddCommand("hello", fli.helloHandler)
+	bookmarksFile, err := databaseFile("bookmarks", firebaseURL, ".json")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	s.AddCommand("cd", fli.cdHandler)
-	s.AddCommand("find", fli.searchHandler)
-	s.AddCommand("ls", fli.lsHandler)
-	s.AddCommand("locate", fli.indexedSearchHandler)
-	s.AddCommand("open", fli.openHandler)
-	s.AddCommand("pwd", fli.pwdHandler)
+	fStore.Bookmarks, err = fuego.OpenBookmarks(bookmarksFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	dir, err := configDir()
+	if err == nil {
+		err = os.MkdirAll(dir, 0700)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fStore.Client().Audit = fuego.OpenAuditLog(filepath.Join(dir, "audit.jsonl"))
+	fStore.Client().ReadOnly = readOnly || profile.ReadOnly
+
+	var script io.Reader
+	if len(args) == 0 {
+		script, err = scriptInput(command, scriptFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	// keep stdout to the commands' output when running a script
+	// or a single command
+	notices := os.Stdout
+	if script != nil || len(args) > 0 {
+		notices = os.Stderr
+	} else {
+		fmt.Printf("Time to fli @ %s\n", fStore.FirebaseURL)
+	}
+
+	if fStore.Client().ReadOnly {
+		fmt.Fprintln(notices, "read-only: write requests will be rejected")
+	}
+	if dryRun {
+		fmt.Fprintln(notices, "dry-run: mutating requests will not be sent")
+	}
+
+	fli.fStore = fStore
+	fli.protected = append(append([]string{}, profile.Protected...), splitPaths(protected)...)
 
+	if len(args) > 0 {
+		os.Exit(fli.runCommand(args))
+	}
+
+	history, err := historyFile(firebaseURL, profile.SharedHistory)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	s, err := shell.InitWithOptions(fStore.Prompt(), shell.Options{
+		HistoryFile: history,
+		HistorySize: historySize,
+		Script:      script,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	s.Register(fli.commands()...)
+	os.Exit(runShell(s, continueOnError))
+}
+
+// runShell processes the commands read by s until it's done and
+// returns the exit status. Scripts exit with 1 after the first failing
+// command, or once done with continueOnError, exit stops them with the
+// status of the commands run so far. The terminal is restored however
+// the shell stops.
+func runShell(s *shell.Shell, continueOnError bool) int {
+	defer s.Cleanup()
+
+	failed := false
 	for s.Next() {
-		s.Process(s.Input())
+		if _, err := s.Process(s.Input()); err != nil && !s.Interactive() {
+			failed = true
+			if !continueOnError {
+				break
+			}
+		}
+	}
+
+	if err := s.Error(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		failed = true
 	}
 
-	if err = s.Error(); err != nil {
-		fmt.Println(err)
+	if failed {
+		return 1
 	}
+
+	return 0
+}
+
+// pathCompleter completes the last argument as a database path
+func (fli *Fli) pathCompleter(args []string) []string {
+	return fli.fStore.Complete(args[len(args)-1])
+}
+
+// keyCompleter completes the last argument as a key of the
+// children at the path in args[1], e.g. find [path] [key]
+func (fli *Fli) keyCompleter(args []string) []string {
+	return fli.fStore.CompleteKeys(args[1], args[len(args)-1])
 }
 
 func (fli *Fli) helloHandler(args []string, s *shell.Shell) (string, error) {
@@ -75,13 +266,125 @@ func (fli *Fli) cdHandler(args []string, s *shell.Shell) (string, error) {
 		dir = args[1]
 	}
 
-	fli.fStore.Cd(dir)
+	if dir != "" && dir != "-" {
+		var err error
+		if dir, err = fli.singlePath(dir); err != nil {
+			return "", err
+		}
+	}
+
+	err := fli.fStore.Cd(dir)
 	s.SetPrompt(fli.fStore.Prompt())
 
-	return "", nil
+	return "", fli.cdWarning(s, err)
+}
+
+// bookmarkHandler manages bookmarks, which can be used as @name
+// anywhere a path is accepted
+func (fli *Fli) bookmarkHandler(args []string, s *shell.Shell) (string, error) {
+	usage := fmt.Errorf("%s: add [name] [path] | ls | rm [name]", args[0])
+	bookmarks := fli.fStore.Bookmarks
+
+	if len(args) < 2 {
+		return "", usage
+	}
+
+	switch args[1] {
+	case "add":
+		if len(args) < 3 {
+			return "", usage
+		}
+
+		p := "."
+		if len(args) > 3 {
+			p = args[3]
+		}
+
+		name := strings.TrimPrefix(args[2], "@")
+		return "", bookmarks.Add(name, fli.fStore.BuildWorkingDirectoryPath(p))
+	case "ls":
+		names := bookmarks.Names()
+		lines := make([]string, 0, len(names))
+		for _, name := range names {
+			p, _ := bookmarks.Get(name)
+			lines = append(lines, fmt.Sprintf("@%s\t~/%s", name, p))
+		}
+
+		return strings.Join(lines, "\n"), nil
+	case "rm":
+		if len(args) < 3 {
+			return "", usage
+		}
+
+		return "", bookmarks.Remove(strings.TrimPrefix(args[2], "@"))
+	default:
+		return "", usage
+	}
+}
+
+func (fli *Fli) pushdHandler(args []string, s *shell.Shell) (string, error) {
+	var dir string
+	if len(args) > 1 {
+		var err error
+		if dir, err = fli.singlePath(args[1]); err != nil {
+			return "", err
+		}
+	}
+
+	err := fli.fStore.Pushd(dir)
+	s.SetPrompt(fli.fStore.Prompt())
+	if err = fli.cdWarning(s, err); err != nil {
+		return "", err
+	}
+
+	return strings.Join(fli.fStore.Dirs(), " "), nil
+}
+
+func (fli *Fli) popdHandler(args []string, s *shell.Shell) (string, error) {
+	if err := fli.fStore.Popd(); err != nil {
+		return "", err
+	}
+	s.SetPrompt(fli.fStore.Prompt())
+
+	return strings.Join(fli.fStore.Dirs(), " "), nil
+}
+
+func (fli *Fli) dirsHandler(args []string, s *shell.Shell) (string, error) {
+	return strings.Join(fli.fStore.Dirs(), " "), nil
+}
+
+// notices returns where handlers print warnings and messages that
+// aren't their output: stdout when interactive, stderr otherwise so
+// the output of a script is only the output of its commands
+func (fli *Fli) notices(s *shell.Shell) io.Writer {
+	if s.Interactive() {
+		return os.Stdout
+	}
+
+	return os.Stderr
+}
+
+// cdWarning prints missing directories as a warning, unless cd
+// checks are strict, along with targets that couldn't be checked,
+// and returns every other error as is
+func (fli *Fli) cdWarning(s *shell.Shell, err error) error {
+	switch err.(type) {
+	case *fuego.NodeNotFoundError:
+		if fli.fStore.CdCheck == fuego.CdCheckStrict {
+			return err
+		}
+	case *fuego.CdCheckError:
+	default:
+		return err
+	}
+
+	fmt.Fprintf(fli.notices(s), "warning: %s\n", err)
+	return nil
 }
 
 func (fli *Fli) lsHandler(args []string, s *shell.Shell) (string, error) {
+	args, withPriority := removeFlag(args, "-p")
+
 	var p string
 
 	if len(args) <= 1 {
@@ -90,7 +393,133 @@ func (fli *Fli) lsHandler(args []string, s *shell.Shell) (string, error) {
 		p = args[1]
 	}
 
-	return fli.fStore.Ls(p)
+	if withPriority {
+		return fli.forEachPath(p, func(p string) (string, error) {
+			return fli.fStore.LsPriority(p)
+		})
+	}
+
+	// the keys are passed on when piped to another command,
+	// keyed by path when p matches several paths
+	values := map[string]interface{}{}
+	out, err := fli.forEachPath(p, func(p string) (string, error) {
+		keys, value, err := fli.fStore.List(p)
+		if err != nil {
+			return "", err
+		}
+
+		if keys == nil {
+			values[p] = value
+			if text, ok := value.(string); ok {
+				return text, nil
+			}
+			return fuego.JSONString(value), nil
+		}
+
+		values[p] = keys
+		return strings.Join(keys, "\n"), nil
+	})
+
+	setValues(s, values)
+	return out, err
+}
+
+// setValues sets the structured output of a command run on
+// several paths: the only value, or the values keyed by path
+func setValues(s *shell.Shell, values map[string]interface{}) {
+	if len(values) == 1 {
+		for _, value := range values {
+			s.SetValue(value)
+		}
+	} else {
+		s.SetValue(values)
+	}
+}
+
+func (fli *Fli) catHandler(args []string, s *shell.Shell) (string, error) {
+	args, withPriority := removeFlag(args, "-p")
+
+	var p string
+
+	if len(args) <= 1 {
+		p = ""
+	} else {
+		p = args[1]
+	}
+
+	// the data is passed on when piped to another command, keyed
+	// by path when p matches several paths
+	values := map[string]interface{}{}
+	out, err := fli.forEachPath(p, func(p string) (string, error) {
+		data, err := fli.fStore.Get(p, withPriority)
+		if err != nil {
+			return "", err
+		}
+
+		values[p] = data
+		return indentJSON(data)
+	})
+
+	setValues(s, values)
+	return out, err
+}
+
+// exportHandler prints the data at a path in export format,
+// including priorities, or writes it to a local file
+func (fli *Fli) exportHandler(args []string, s *shell.Shell) (string, error) {
+	if len(args) < 2 {
+		return "", fmt.Errorf("%s: [path] [file]", args[0])
+	}
+
+	p, err := fli.singlePath(args[1])
+	if err != nil {
+		return "", err
+	}
+
+	data, err := fli.fStore.Export(p)
+	if err != nil {
+		return "", err
+	}
+
+	if len(args) < 3 {
+		return data, nil
+	}
+
+	if err = ioutil.WriteFile(args[2], []byte(data+"\n"), 0644); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("exported %s to %s", p, args[2]), nil
+}
+
+// priorityHandler prints the priority of a path, or replaces it
+// when a value is given. Use null to remove the priority.
+func (fli *Fli) priorityHandler(args []string, s *shell.Shell) (string, error) {
+	if len(args) < 2 {
+		return "", fmt.Errorf("%s: [path] [priority]", args[0])
+	}
+
+	if len(args) == 2 {
+		return fli.forEachPath(args[1], func(p string) (string, error) {
+			priority, err := fli.fStore.Priority(p)
+			if err != nil {
+				return "", err
+			}
+
+			return fuego.JSONString(priority), nil
+		})
+	}
+
+	priority := parseValue(strings.Join(args[2:], " "))
+	switch priority.(type) {
+	case nil, float64, string:
+	default:
+		return "", fmt.Errorf("%s: priority must be a number, string or null", args[0])
+	}
+
+	return fli.forEachWrite(s, args[1], func(p string) (string, error) {
+		return "", fli.fStore.SetPriority(p, priority)
+	})
 }
 
 func (fli *Fli) openHandler(args []string, s *shell.Shell) (string, error) {
@@ -102,6 +531,11 @@ func (fli *Fli) openHandler(args []string, s *shell.Shell) (string, error) {
 		p = args[1]
 	}
 
+	p, err := fli.singlePath(p)
+	if err != nil {
+		return "", err
+	}
+
 	url := fli.fStore.FirebaseURLFromWorkingDirectory(p)
 	open.Start(url)
 
@@ -113,33 +547,341 @@ func (fli *Fli) pwdHandler(args []string, s *shell.Shell) (string, error) {
 	return fli.fStore.FirebaseURLFromWorkingDirectory("."), nil
 }
 
-// Supports wild card searching
+// Supports wild card searching. Falls back to a client side search
+// when the key isn't indexed, --rules prints the rules needed to
+// index it and --fix-rules adds them to the database's rules.
 func (fli *Fli) indexedSearchHandler(args []string, s *shell.Shell) (string, error) {
-	if len(args) < 3 {
-		return "", fmt.Errorf("%s: [path] [key] [value]", args[0])
+	args, printRules := removeFlag(args, "--rules")
+	args, fixRules := removeFlag(args, "--fix-rules")
+
+	if len(args) < 4 {
+		return "", fmt.Errorf("%s: [--rules|--fix-rules] [path] [key] [value]", args[0])
 	}
 
-	// Add support for searching from top level with ~
-	// Allows user to seach in paths not based on cwd
-	p := args[1]
-	p = fli.fStore.BuildWorkingDirectoryPath(p)
 	key := args[2]
 	value := args[3]
 
-	return fli.fStore.IndexedSearch(p, key, value)
+	return fli.forEachPath(args[1], func(p string) (string, error) {
+		p = fli.fStore.BuildWorkingDirectoryPath(p)
+		return fli.indexedSearch(s, args[0], p, key, value, printRules, fixRules)
+	})
+}
+
+// indexedSearch searches p and falls back to a client side
+// search when the key isn't indexed
+func (fli *Fli) indexedSearch(s *shell.Shell, command string, p string, key string, value string, printRules bool, fixRules bool) (string, error) {
+	out, err := fli.fStore.IndexedSearch(p, key, value)
+
+	indexErr, ok := err.(*fuego.IndexError)
+	if !ok {
+		return out, err
+	}
+
+	notices := fli.notices(s)
+	fmt.Fprintf(notices, "warning: %s, searching client side instead\n", indexErr)
+
+	switch {
+	case fixRules:
+		if message, err := fli.addIndexOn(s, indexErr); err != nil {
+			fmt.Fprintln(notices, err)
+		} else {
+			fmt.Fprintln(notices, message)
+		}
+	case printRules:
+		fmt.Fprintln(notices, indexErr.Snippet())
+	default:
+		fmt.Fprintf(notices, "run %s --rules to print the .indexOn rule or --fix-rules to add it\n", command)
+	}
+
+	return fli.fStore.Search(p, key, value)
+}
+
+// addIndexOn patches the database's rules to index the key
+// from indexErr, confirming the change with the user first
+func (fli *Fli) addIndexOn(s *shell.Shell, indexErr *fuego.IndexError) (string, error) {
+	rules, err := fli.fStore.Rules()
+	if err != nil {
+		return "", err
+	}
+
+	patched, err := fuego.AddIndexOn([]byte(rules), indexErr.Path, indexErr.Key)
+	if err != nil {
+		return "", fmt.Errorf("%s, the rule to add is:\n%s", err, indexErr.Snippet())
+	}
+
+	if strings.Contains(rules, "//") || strings.Contains(rules, "/*") {
+		fmt.Fprintln(fli.notices(s), "warning: comments in the rules will be removed")
+	}
+
+	return fli.uploadRules(s, string(patched))
 }
 
 func (fli *Fli) searchHandler(args []string, s *shell.Shell) (string, error) {
-	if len(args) < 3 {
+	if len(args) < 4 {
 		return "", fmt.Errorf("%s: [path] [key] [value]", args[0])
 	}
 
-	// Add support for searching from top level with ~
-	// Allows user to seach in paths not based on cwd
-	p := args[1]
-	p = fli.fStore.BuildWorkingDirectoryPath(p)
 	key := args[2]
 	value := args[3]
 
-	return fli.fStore.Search(p, key, value)
+	return fli.forEachPath(args[1], func(p string) (string, error) {
+		p = fli.fStore.BuildWorkingDirectoryPath(p)
+		return fli.fStore.Search(p, key, value)
+	})
+}
+
+func (fli *Fli) setHandler(args []string, s *shell.Shell) (string, error) {
+	stdin := s.Stdin()
+	if len(args) < 3 && (len(args) < 2 || stdin == nil) {
+		return "", fmt.Errorf("%s: [path] [value]", args[0])
+	}
+
+	var value interface{}
+	if len(args) < 3 {
+		// the value is piped in, e.g. cat users/a | set users/b
+		value = stdin.Value
+		if value == nil {
+			value = parseValue(strings.TrimSpace(stdin.Text))
+		}
+	} else {
+		// values can be quoted, unquoted values with spaces
+		// are still joined, e.g. set name Jane Doe
+		value = parseValue(strings.Join(args[2:], " "))
+
+		// quoted scalars are strings, e.g. set code "123", quoted
+		// objects and arrays are still JSON, e.g. set cfg '{"a": 1}'
+		switch value.(type) {
+		case float64, bool, nil:
+			for i := range args[2:] {
+				if s.Quoted(i + 2) {
+					value = strings.Join(args[2:], " ")
+					break
+				}
+			}
+		}
+	}
+
+	return fli.forEachWrite(s, args[1], func(p string) (string, error) {
+		return "", fli.fStore.Set(p, value)
+	})
+}
+
+func (fli *Fli) incrHandler(args []string, s *shell.Shell) (string, error) {
+	if len(args) < 2 {
+		return "", fmt.Errorf("%s: [path] [delta]", args[0])
+	}
+
+	delta := 1.0
+	if len(args) > 2 {
+		var err error
+		delta, err = strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return "", fmt.Errorf("%s: [path] [delta]", args[0])
+		}
+	}
+
+	return fli.forEachWrite(s, args[1], func(p string) (string, error) {
+		return "", fli.fStore.Incr(p, delta)
+	})
+}
+
+func (fli *Fli) rmHandler(args []string, s *shell.Shell) (string, error) {
+	if len(args) < 2 {
+		return "", fmt.Errorf("%s: [path]", args[0])
+	}
+
+	return fli.forEachWrite(s, args[1], func(p string) (string, error) {
+		return "", fli.fStore.Rm(p)
+	})
+}
+
+func (fli *Fli) mvHandler(args []string, s *shell.Shell) (string, error) {
+	if len(args) < 3 {
+		return "", fmt.Errorf("%s: [src] [dst]", args[0])
+	}
+
+	src, err := fli.singlePath(args[1])
+	if err != nil {
+		return "", err
+	}
+
+	if err := fli.confirmWrite(s, src, args[2]); err != nil {
+		return "", err
+	}
+
+	return "", fli.fStore.Mv(src, args[2])
+}
+
+func (fli *Fli) dryRunHandler(args []string, s *shell.Shell) (string, error) {
+	client := fli.fStore.Client()
+
+	if len(args) > 1 {
+		switch args[1] {
+		case "on":
+			client.DryRun = true
+		case "off":
+			client.DryRun = false
+		default:
+			return "", fmt.Errorf("%s: [on|off]", args[0])
+		}
+	}
+
+	if client.DryRun {
+		return "dry-run: on", nil
+	}
+
+	return "dry-run: off", nil
+}
+
+// suHandler makes the following requests as another user to test
+// security rules, optionally with custom claims read from a JSON file.
+// "su -" returns to the service account's admin access.
+func (fli *Fli) suHandler(args []string, s *shell.Shell) (string, error) {
+	if len(args) < 2 {
+		if uid := fli.fStore.Impersonating(); uid != "" {
+			return uid, nil
+		}
+		return "admin", nil
+	}
+
+	if args[1] == "-" {
+		fli.fStore.StopImpersonating()
+		s.SetPrompt(fli.fStore.Prompt())
+		return "", nil
+	}
+
+	var claims map[string]interface{}
+	if len(args) > 2 {
+		b, err := ioutil.ReadFile(args[2])
+		if err != nil {
+			return "", err
+		}
+
+		if err = json.Unmarshal(b, &claims); err != nil {
+			return "", fmt.Errorf("%s: %s: %s", args[0], args[2], err)
+		}
+	}
+
+	fli.fStore.Impersonate(args[1], claims)
+	s.SetPrompt(fli.fStore.Prompt())
+
+	return "", nil
+}
+
+func (fli *Fli) undoHandler(args []string, s *shell.Shell) (string, error) {
+	n := 1
+	if len(args) > 1 {
+		var err error
+		n, err = strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return "", fmt.Errorf("%s: [n]", args[0])
+		}
+	}
+
+	// undoing writes to protected paths needs confirmation too
+	if journal := fli.fStore.Journal; journal != nil {
+		var paths []string
+		for _, entry := range journal.Recent(n) {
+			paths = append(paths, "~/"+entry.Path)
+		}
+
+		if err := fli.confirmWrite(s, paths...); err != nil {
+			return "", err
+		}
+	}
+
+	undone, err := fli.fStore.Undo(n)
+
+	format := "restored ~/%s to %s"
+	if fli.fStore.Client().DryRun {
+		format = "dry-run: would restore ~/%s to %s"
+	}
+
+	lines := make([]string, 0, len(undone))
+	for _, entry := range undone {
+		lines = append(lines, fmt.Sprintf(format, entry.Path, fuego.JSONString(entry.Prev)))
+	}
+
+	if len(undone) == 0 && err == nil {
+		return "undo: nothing to undo", nil
+	}
+
+	return strings.Join(lines, "\n"), err
+}
+
+func (fli *Fli) historyHandler(args []string, s *shell.Shell) (string, error) {
+	if len(args) > 1 && args[1] == "--writes" {
+		if fli.fStore.Journal == nil {
+			return "", nil
+		}
+
+		entries := fli.fStore.Journal.Entries()
+		lines := make([]string, 0, len(entries))
+		for i, entry := range entries {
+			line := fmt.Sprintf("%4d  %s  ~/%s  %s -> %s",
+				i+1, entry.Time.Format("2006-01-02 15:04:05"), entry.Path,
+				fuego.JSONString(entry.Prev), fuego.JSONString(entry.Value))
+			lines = append(lines, line)
+		}
+
+		return strings.Join(lines, "\n"), nil
+	}
+
+	cmds := s.History().All()
+	lines := make([]string, 0, len(cmds))
+	for i, cmd := range cmds {
+		lines = append(lines, fmt.Sprintf("%4d  %s", i+1, cmd))
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// indentJSON encodes value as indented JSON for display
+func indentJSON(value interface{}) (string, error) {
+	b, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// removeFlag removes every occurrence of flag from args and
+// returns true if it was found
+func removeFlag(args []string, flag string) ([]string, bool) {
+	found := false
+	remaining := make([]string, 0, len(args))
+
+	for _, arg := range args {
+		if arg == flag {
+			found = true
+			continue
+		}
+		remaining = append(remaining, arg)
+	}
+
+	return remaining, found
+}
+
+// parseValue interprets input as JSON when possible, otherwise
+// the raw input is used as a string value. Server values
+// can be written with @now and @incr(n).
+func parseValue(input string) interface{} {
+	if input == "@now" {
+		return fuego.ServerTimestamp()
+	}
+
+	if strings.HasPrefix(input, "@incr(") && strings.HasSuffix(input, ")") {
+		n := strings.TrimSuffix(strings.TrimPrefix(input, "@incr("), ")")
+		if delta, err := strconv.ParseFloat(n, 64); err == nil {
+			return fuego.ServerIncrement(delta)
+		}
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(input), &value); err != nil {
+		return input
+	}
+
+	return value
 }
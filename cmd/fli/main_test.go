@@ -0,0 +1,153 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sneakybueno/fli/fuego"
+	"github.com/sneakybueno/fli/shell"
+)
+
+func TestSetQuotedValues(t *testing.T) {
+	writes := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			body, _ := ioutil.ReadAll(r.Body)
+			writes[r.URL.Path] = string(body)
+		}
+		w.Write([]byte(`null`))
+	}))
+	defer server.Close()
+
+	fli := &Fli{fStore: fuego.NewFStoreWithClient(&fuego.FClient{FirebaseURL: server.URL + "/"})}
+	s := shell.New("")
+	s.Register(fli.commands()...)
+
+	for _, input := range []string{
+		`set number 123`,
+		`set code "123"`,
+		`set flag 'true'`,
+		`set name "Jane" Doe`,
+		`set user {"age": 30}`,
+		`set cfg '{"a": 1}'`,
+		`set list "[1, 2]"`,
+		`set empty 'null'`,
+	} {
+		if _, err := s.Process(input); err != nil {
+			t.Fatalf("%s: %s", input, err)
+		}
+	}
+
+	expected := map[string]string{
+		"/number.json": `123`,
+		"/code.json":   `"123"`,
+		"/flag.json":   `"true"`,
+		"/name.json":   `"Jane Doe"`,
+		"/user.json":   `{"age":30}`,
+		"/cfg.json":    `{"a":1}`,
+		"/list.json":   `[1,2]`,
+		"/empty.json":  `"null"`,
+	}
+
+	for path, body := range expected {
+		if writes[path] != body {
+			t.Errorf("Expected %s to be set to %s, got %s", path, body, writes[path])
+		}
+	}
+}
+
+func TestNoticesNonInteractive(t *testing.T) {
+	fli := &Fli{}
+	if w := fli.notices(shell.New("")); w != os.Stderr {
+		t.Errorf("Expected notices to go to stderr when not interactive")
+	}
+}
+
+func TestLsPipes(t *testing.T) {
+	writes := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			body, _ := ioutil.ReadAll(r.Body)
+			writes[r.URL.Path] = string(body)
+		}
+
+		if r.URL.Path == "/users.json" && r.Method == "GET" {
+			w.Write([]byte(`{"corgi": true, "admin": true, "bueno": true, "sysadmin": true}`))
+			return
+		}
+		w.Write([]byte(`null`))
+	}))
+	defer server.Close()
+
+	fli := &Fli{fStore: fuego.NewFStoreWithClient(&fuego.FClient{FirebaseURL: server.URL + "/"})}
+	s := shell.New("")
+	s.Register(fli.commands()...)
+
+	out, err := s.Process("ls users")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "admin\nbueno\ncorgi\nsysadmin"; out != expected {
+		t.Errorf("Expected one sorted key per line, %q, got %q", expected, out)
+	}
+
+	// shell commands get the keys
+	if _, err := s.Process("ls users | set keys"); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := `["admin","bueno","corgi","sysadmin"]`; writes["/keys.json"] != expected {
+		t.Errorf("Expected %s, got %s", expected, writes["/keys.json"])
+	}
+
+	if _, err := exec.LookPath("grep"); err != nil {
+		t.Skip("grep not found")
+	}
+
+	dir, err := ioutil.TempDir("", "ls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "out")
+	if _, err := s.Process("ls users | grep admin > " + file); err != nil {
+		t.Fatal(err)
+	}
+
+	grepped, _ := ioutil.ReadFile(file)
+	if expected := "admin\nsysadmin\n"; string(grepped) != expected {
+		t.Errorf("Expected %q, got %q", expected, grepped)
+	}
+}
+
+func TestRunShellStatus(t *testing.T) {
+	tests := []struct {
+		script          string
+		continueOnError bool
+		expected        int
+	}{
+		{"help\n", false, 0},
+		{"nope\nhelp\n", false, 1},
+		{"nope\nhelp\n", true, 1},
+		{"help\nexit\nnope\n", false, 0},
+	}
+
+	for _, test := range tests {
+		s, err := shell.InitWithOptions("", shell.Options{Script: strings.NewReader(test.script)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if status := runShell(s, test.continueOnError); status != test.expected {
+			t.Errorf("%q: expected status %d, got %d", test.script, test.expected, status)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/sneakybueno/fli/fuego"
+	"github.com/sneakybueno/fli/shell"
+)
+
+const rulesUsage = "rules: get [file] | set [file] | edit | diff [file]"
+
+// rulesHandler downloads, diffs, edits and uploads
+// the database's security rules
+func (fli *Fli) rulesHandler(args []string, s *shell.Shell) (string, error) {
+	if len(args) < 2 {
+		return "", fmt.Errorf(rulesUsage)
+	}
+
+	switch args[1] {
+	case "get":
+		rules, err := fli.fStore.Rules()
+		if err != nil || len(args) < 3 {
+			return strings.TrimSuffix(rules, "\n"), err
+		}
+
+		if err = ioutil.WriteFile(args[2], []byte(rules), 0644); err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("saved rules to %s", args[2]), nil
+	case "diff":
+		if len(args) < 3 {
+			return "", fmt.Errorf(rulesUsage)
+		}
+
+		rules, err := ioutil.ReadFile(args[2])
+		if err != nil {
+			return "", err
+		}
+
+		return fli.fStore.RulesDiff(string(rules))
+	case "set":
+		if len(args) < 3 {
+			return "", fmt.Errorf(rulesUsage)
+		}
+
+		rules, err := ioutil.ReadFile(args[2])
+		if err != nil {
+			return "", err
+		}
+
+		return fli.uploadRules(s, string(rules))
+	case "edit":
+		return fli.editRules(s)
+	default:
+		return "", fmt.Errorf(rulesUsage)
+	}
+}
+
+// editRules opens the database's rules in $EDITOR
+// and uploads the result once it's valid
+func (fli *Fli) editRules(s *shell.Shell) (string, error) {
+	rules, err := fli.fStore.Rules()
+	if err != nil {
+		return "", err
+	}
+
+	f, err := ioutil.TempFile("", "fli-rules-*.json")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(rules)
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		if err = runEditor(f.Name()); err != nil {
+			return "", err
+		}
+
+		edited, err := ioutil.ReadFile(f.Name())
+		if err != nil {
+			return "", err
+		}
+
+		if _, err = fuego.ParseRules(edited); err == nil {
+			return fli.uploadRules(s, string(edited))
+		}
+
+		fmt.Fprintln(fli.notices(s), err)
+		answer, err := s.ReadLine("edit again? [Y/n] ")
+		if err != nil {
+			return "", err
+		}
+
+		if strings.HasPrefix(strings.ToLower(answer), "n") {
+			return "rules were not changed", nil
+		}
+	}
+}
+
+// uploadRules validates rules, shows the diff against the
+// database's rules and uploads them once confirmed
+func (fli *Fli) uploadRules(s *shell.Shell, rules string) (string, error) {
+	if _, err := fuego.ParseRules([]byte(rules)); err != nil {
+		return "", err
+	}
+
+	diff, err := fli.fStore.RulesDiff(rules)
+	if err != nil {
+		return "", err
+	}
+
+	if diff == "" {
+		return "rules are unchanged", nil
+	}
+
+	fmt.Fprint(fli.notices(s), diff)
+	answer, err := s.ReadLine("upload these rules? [y/N] ")
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasPrefix(strings.ToLower(answer), "y") {
+		return "rules were not changed", nil
+	}
+
+	if err = fli.fStore.SetRules(rules); err != nil {
+		return "", err
+	}
+
+	return "rules updated", nil
+}
+
+// runEditor opens file in the user's editor, attached to the terminal
+func runEditor(file string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	parts := strings.Fields(editor)
+	cmd := exec.Command(parts[0], append(parts[1:], file)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sneakybueno/fli/shell"
+)
+
+// scriptInput returns the commands to run non-interactively: the
+// command given with -c, the script given with -f, or stdin when it
+// isn't a terminal. Returns nil to run interactively.
+func scriptInput(command string, file string) (io.Reader, error) {
+	switch {
+	case command != "":
+		return strings.NewReader(command), nil
+	case file != "":
+		return os.Open(file)
+	case !shell.IsTerminal(os.Stdin):
+		return os.Stdin, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,145 @@
+package fuego
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+)
+
+// AuditEntry is a single line of the audit log, written
+// for every non-GET request sent by an FClient
+type AuditEntry struct {
+	Time        time.Time `json:"time"`
+	User        string    `json:"user"`
+	Account     string    `json:"account"`
+	AuthUID     string    `json:"authUid,omitempty"`
+	Database    string    `json:"database"`
+	Method      string    `json:"method"`
+	Path        string    `json:"path"`
+	PayloadHash string    `json:"payloadHash"`
+	Status      int       `json:"status"`
+}
+
+// AuditLog is an append only log of writes stored as JSON lines
+type AuditLog struct {
+	file string
+
+	// User is the OS user recorded with each entry
+	User string
+}
+
+// OpenAuditLog returns an audit log that appends to file
+func OpenAuditLog(file string) *AuditLog {
+	return &AuditLog{
+		file: file,
+		User: currentUser(),
+	}
+}
+
+// Record appends entry to the log
+func (a *AuditLog) Record(entry AuditEntry) error {
+	if entry.User == "" {
+		entry.User = a.User
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(a.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
+
+// AuditFilter selects audit entries, empty fields match everything
+type AuditFilter struct {
+	Database string
+	Method   string
+	Path     string
+	User     string
+	Since    time.Time
+}
+
+// Match returns true if entry passes the filter. Path
+// matches the entry's path and everything under it.
+func (f AuditFilter) Match(entry AuditEntry) bool {
+	if f.Database != "" && entry.Database != f.Database {
+		return false
+	}
+
+	if f.Method != "" && !strings.EqualFold(entry.Method, f.Method) {
+		return false
+	}
+
+	if f.User != "" && entry.User != f.User && entry.Account != f.User {
+		return false
+	}
+
+	if f.Path != "" && entry.Path != f.Path && !strings.HasPrefix(entry.Path, f.Path+"/") {
+		return false
+	}
+
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+
+	return true
+}
+
+// Entries reads the log and returns the entries that
+// pass filter, oldest first
+func (a *AuditLog) Entries(filter AuditFilter) ([]AuditEntry, error) {
+	f, err := os.Open(a.file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []AuditEntry
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+
+		if filter.Match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+// payloadHash returns the hex encoded sha256 of a request body
+func payloadHash(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+
+	return os.Getenv("USER")
+}
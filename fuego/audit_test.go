@@ -0,0 +1,100 @@
+package fuego_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sneakybueno/fli/fuego"
+)
+
+func TestAuditLogRecordsWrites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fli-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/locked.json" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error": "Permission denied"}`)
+			return
+		}
+		fmt.Fprint(w, `null`)
+	}))
+	defer server.Close()
+
+	audit := fuego.OpenAuditLog(filepath.Join(dir, "audit.jsonl"))
+	audit.User = "bueno"
+
+	fClient := &fuego.FClient{
+		FirebaseURL: server.URL + "/",
+		Audit:       audit,
+		Account:     "fli@go-fli.iam.gserviceaccount.com",
+	}
+
+	fClient.Get("users", nil)
+	fClient.Set("users/bueno", "bueno")
+	fClient.Delete("locked")
+
+	entries, err := audit.Entries(fuego.AuditFilter{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+
+	set := entries[0]
+	if set.Method != "PUT" || set.Path != "users/bueno" || set.Status != 200 ||
+		set.User != "bueno" || set.Account != fClient.Account || set.PayloadHash == "" {
+		t.Errorf("Unexpected entry %+v", set)
+	}
+
+	del := entries[1]
+	if del.Method != "DELETE" || del.Status != 401 || del.PayloadHash != "" {
+		t.Errorf("Unexpected entry %+v", del)
+	}
+
+	entries, err = audit.Entries(fuego.AuditFilter{Path: "users"})
+	if err != nil || len(entries) != 1 {
+		t.Errorf("Expected 1 entry under users, got %d (%v)", len(entries), err)
+	}
+}
+
+func TestAuditFailureKeepsWrites(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `"bueno"`)
+	}))
+	defer server.Close()
+
+	var warnings bytes.Buffer
+	fClient := &fuego.FClient{
+		FirebaseURL: server.URL + "/",
+		Audit:       fuego.OpenAuditLog(filepath.Join(os.DevNull, "audit.jsonl")),
+		Warnings:    &warnings,
+	}
+
+	fStore := fuego.NewFStoreWithClient(fClient)
+	fStore.Journal, _ = fuego.OpenJournal("")
+
+	if err := fStore.Set("users/bueno", "bueno"); err != nil {
+		t.Errorf("Expected the write to succeed, got %s", err)
+	}
+
+	if entries := fStore.Journal.Entries(); len(entries) != 1 {
+		t.Errorf("Expected the write to be journaled, got %+v", entries)
+	}
+
+	if !strings.Contains(warnings.String(), "PUT users/bueno was sent but not audited") {
+		t.Errorf("Expected an audit warning, got %q", warnings.String())
+	}
+}
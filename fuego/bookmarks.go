@@ -0,0 +1,118 @@
+package fuego
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Bookmarks maps names to absolute database paths. Bookmarks can
+// be used anywhere a path is accepted as @name, or @name/child.
+// When opened with a file, bookmarks are persisted as JSON.
+type Bookmarks struct {
+	file  string
+	paths map[string]string
+}
+
+// OpenBookmarks loads the bookmarks stored in file.
+// Pass "" to keep the bookmarks in memory only.
+func OpenBookmarks(file string) (*Bookmarks, error) {
+	b := &Bookmarks{
+		file:  file,
+		paths: map[string]string{},
+	}
+
+	if file == "" {
+		return b, nil
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(data, &b.paths); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Add saves path, relative to the root of the database, as name.
+// An existing bookmark with the same name is replaced.
+func (b *Bookmarks) Add(name string, path string) error {
+	if name == "" || strings.ContainsAny(name, "/ ") {
+		return fmt.Errorf("bookmark: invalid name: %q", name)
+	}
+
+	b.paths[name] = path
+	return b.save()
+}
+
+// Remove deletes the bookmark called name
+func (b *Bookmarks) Remove(name string) error {
+	if _, ok := b.paths[name]; !ok {
+		return fmt.Errorf("bookmark: not found: %s", name)
+	}
+
+	delete(b.paths, name)
+	return b.save()
+}
+
+// Get returns the path saved as name
+func (b *Bookmarks) Get(name string) (string, bool) {
+	path, ok := b.paths[name]
+	return path, ok
+}
+
+// Names returns the names of every bookmark, sorted
+func (b *Bookmarks) Names() []string {
+	names := make([]string, 0, len(b.paths))
+	for name := range b.paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Expand replaces a leading @name in p with the bookmarked path,
+// returning an absolute path. p is returned as is when it doesn't
+// start with a known bookmark.
+func (b *Bookmarks) Expand(p string) string {
+	if b == nil || !strings.HasPrefix(p, "@") {
+		return p
+	}
+
+	name := strings.TrimPrefix(p, "@")
+	rest := ""
+	if i := strings.Index(name, "/"); i >= 0 {
+		name, rest = name[:i], name[i:]
+	}
+
+	path, ok := b.paths[name]
+	if !ok {
+		return p
+	}
+
+	return "~/" + path + rest
+}
+
+func (b *Bookmarks) save() error {
+	if b.file == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(b.paths, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(b.file, data, 0600)
+}
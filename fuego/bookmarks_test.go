@@ -0,0 +1,73 @@
+package fuego_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sneakybueno/fli/fuego"
+)
+
+func TestBookmarks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fli-bookmarks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "bookmarks.json")
+
+	bookmarks, err := fuego.OpenBookmarks(file)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	bookmarks.Add("dev", "users/bueno/dev")
+	bookmarks.Add("orders", "orders")
+	if err = bookmarks.Add("bad/name", "orders"); err == nil {
+		t.Errorf("Expected an error for an invalid name")
+	}
+
+	bookmarks, err = fuego.OpenBookmarks(file)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	if names := strings.Join(bookmarks.Names(), " "); names != "dev orders" {
+		t.Errorf("Expected dev orders, got %s", names)
+	}
+
+	fStore := &fuego.FStore{
+		FirebaseURL: firebaseTestingURL,
+		Bookmarks:   bookmarks,
+	}
+	fStore.Cd("users/corgi")
+
+	tests := map[string]string{
+		"@dev":          "users/bueno/dev",
+		"@dev/phone":    "users/bueno/dev/phone",
+		"@dev/../..":    "users",
+		"@unknown/key":  "users/corgi/@unknown/key",
+		"friends/@dev":  "users/corgi/friends/@dev",
+		"~/@orders/123": "@orders/123",
+	}
+
+	for given, expected := range tests {
+		p := fStore.BuildWorkingDirectoryPath(given)
+		if p != expected {
+			t.Errorf("%s: Expected %s, got %s", given, expected, p)
+		}
+	}
+
+	fStore.Cd("@orders")
+	if wd := fStore.Wd(); wd != "orders" {
+		t.Errorf("Expected orders, got %s", wd)
+	}
+
+	bookmarks.Remove("orders")
+	if _, ok := bookmarks.Get("orders"); ok {
+		t.Errorf("Expected orders to be removed")
+	}
+}
@@ -0,0 +1,123 @@
+package fuego
+
+import (
+	"sort"
+	"strings"
+)
+
+// Complete returns the paths that complete word, a partial path
+// relative to the working directory, e.g. "users/bu" completes to
+// "users/bueno/". Children are listed with a shallow get that is
+// cached until the next write. Paths to objects end with "/".
+// Bookmark names are completed for words starting with @.
+func (fs *FStore) Complete(word string) []string {
+	if strings.HasPrefix(word, "@") && !strings.Contains(word, "/") {
+		return fs.completeBookmarks(word)
+	}
+
+	dir, prefix := "", word
+	if i := strings.LastIndex(word, "/"); i >= 0 {
+		dir, prefix = word[:i+1], word[i+1:]
+	}
+
+	children, err := fs.children(fs.BuildWorkingDirectoryPath(dir))
+	if err != nil {
+		return nil
+	}
+
+	var candidates []string
+	for _, key := range sortedKeys(children) {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		candidate := dir + key
+		if children[key] == true {
+			candidate += "/"
+		}
+
+		candidates = append(candidates, candidate)
+	}
+
+	return candidates
+}
+
+// CompleteKeys returns the keys of the children of the objects
+// at p that start with prefix, e.g. for the key argument of find.
+// Only the first child is sampled, since children usually share
+// the same keys.
+func (fs *FStore) CompleteKeys(p string, prefix string) []string {
+	path := fs.BuildWorkingDirectoryPath(p)
+
+	children, err := fs.children(path)
+	if err != nil {
+		return nil
+	}
+
+	keys := sortedKeys(children)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	grandchildren, err := fs.children(joinPath(path, keys[0]))
+	if err != nil {
+		return nil
+	}
+
+	var candidates []string
+	for _, key := range sortedKeys(grandchildren) {
+		if strings.HasPrefix(key, prefix) {
+			candidates = append(candidates, key)
+		}
+	}
+
+	return candidates
+}
+
+func (fs *FStore) completeBookmarks(word string) []string {
+	if fs.Bookmarks == nil {
+		return nil
+	}
+
+	var candidates []string
+	for _, name := range fs.Bookmarks.Names() {
+		if strings.HasPrefix("@"+name, word) {
+			candidates = append(candidates, "@"+name+"/")
+		}
+	}
+	sort.Strings(candidates)
+
+	return candidates
+}
+
+// children returns the shallow children of path,
+// cached until the next write
+func (fs *FStore) children(path string) (map[string]interface{}, error) {
+	data, err := fs.shallowGet(path)
+	if err != nil {
+		return nil, err
+	}
+
+	children, _ := data.(map[string]interface{})
+	return children, nil
+}
+
+// shallowGet returns the shallow get of path,
+// cached until the next write
+func (fs *FStore) shallowGet(path string) (interface{}, error) {
+	if data, ok := fs.shallow[path]; ok {
+		return data, nil
+	}
+
+	data, err := fs.fClient.ShallowGet(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if fs.shallow == nil {
+		fs.shallow = map[string]interface{}{}
+	}
+	fs.shallow[path] = data
+
+	return data, nil
+}
@@ -0,0 +1,75 @@
+package fuego_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sneakybueno/fli/fuego"
+)
+
+func TestComplete(t *testing.T) {
+	requests := 0
+	data := map[string]string{
+		"/.json":           `{"users": true, "orders": true, "version": 2}`,
+		"/users.json":      `{"bueno": true, "boom": true, "corgi": true}`,
+		"/users/boom.json": `{"name": "boom", "email": "boom@fli.dev"}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if body, ok := data[r.URL.Path]; ok {
+			fmt.Fprint(w, body)
+			return
+		}
+		fmt.Fprint(w, `null`)
+	}))
+	defer server.Close()
+
+	bookmarks, _ := fuego.OpenBookmarks("")
+	bookmarks.Add("dev", "users/bueno")
+
+	fStore := fuego.NewFStoreWithClient(&fuego.FClient{FirebaseURL: server.URL + "/"})
+	fStore.Bookmarks = bookmarks
+
+	cases := []struct {
+		word     string
+		expected string
+	}{
+		{"", "orders/ users/ version"},
+		{"u", "users/"},
+		{"users/b", "users/boom/ users/bueno/"},
+		{"~/users/c", "~/users/corgi/"},
+		{"@d", "@dev/"},
+		{"missing/x", ""},
+	}
+
+	for _, c := range cases {
+		if actual := strings.Join(fStore.Complete(c.word), " "); actual != c.expected {
+			t.Errorf("Complete(%q): expected %q, got %q", c.word, c.expected, actual)
+		}
+	}
+
+	fStore.Cd("users")
+	if actual := strings.Join(fStore.Complete("../o"), " "); actual != "../orders/" {
+		t.Errorf("Expected ../orders/, got %q", actual)
+	}
+
+	if actual := strings.Join(fStore.CompleteKeys(".", ""), " "); actual != "email name" {
+		t.Errorf("Expected email name, got %q", actual)
+	}
+
+	// root, users and users/boom are cached
+	if requests != 4 {
+		t.Errorf("Expected 4 requests, got %d", requests)
+	}
+
+	fStore.Complete("b")
+	fStore.Complete("~/v")
+	fStore.CompleteKeys(".", "n")
+	if requests != 4 {
+		t.Errorf("Expected completions to be cached, got %d requests", requests)
+	}
+}
@@ -1,14 +1,28 @@
 package fuego
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"time"
 
 	"golang.org/x/oauth2/google"
 )
 
+// ErrReadOnly is returned for any non-GET request made
+// while the client is in read-only mode
+var ErrReadOnly = errors.New("fuego: read-only mode, refusing to send write request")
+
+// ErrETagMismatch is returned by conditional requests when the
+// data at the path changed since its ETag was read
+var ErrETagMismatch = errors.New("fuego: data changed remotely since it was read")
+
 const (
 	firebaseDatabaseScope = "https://www.googleapis.com/auth/firebase.database"
 	firebaseUserInfoScope = "https://www.googleapis.com/auth/userinfo.email"
@@ -21,6 +35,32 @@ type FClient struct {
 	client *http.Client
 
 	FirebaseURL string
+
+	// DryRun logs mutating requests to Log instead of sending them.
+	// Reads are still performed.
+	DryRun bool
+	Log    io.Writer
+
+	// ReadOnly rejects every non-GET request with ErrReadOnly.
+	ReadOnly bool
+
+	// Audit records every non-GET request that is sent,
+	// nil disables auditing. Account is the service account's
+	// email and is recorded with each entry.
+	Audit   *AuditLog
+	Account string
+
+	// Warnings receives problems that don't fail a request, e.g. an
+	// audit entry that couldn't be recorded for a successful write.
+	// nil writes them to stderr.
+	Warnings io.Writer
+
+	// AuthOverride is sent as auth_variable_override with every
+	// data request so security rules are evaluated as if the request
+	// was made by that user, nil uses the service account's access.
+	// Requests for the rules themselves always use the service
+	// account's access.
+	AuthOverride map[string]interface{}
 }
 
 // NewFClient builds a firebase client based on the 2 passed in params.
@@ -45,6 +85,8 @@ func NewFClient(firebaseURL string, serviceAccountPath string) (*FClient, error)
 	fClient := &FClient{
 		client:      client,
 		FirebaseURL: firebaseURL,
+		Log:         os.Stdout,
+		Account:     jwtConfig.Email,
 	}
 
 	return fClient, nil
@@ -78,15 +120,151 @@ func (fc *FClient) Get(path string, params map[string]string) (interface{}, erro
 	return fc.do(request)
 }
 
+// GetWithETag performs a http get request for the given path and
+// returns the data's ETag for use in conditional requests
+func (fc *FClient) GetWithETag(path string) (interface{}, string, error) {
+	p, err := fc.buildURL(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	request, err := http.NewRequest("GET", p, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	request.Header.Set("X-Firebase-ETag", "true")
+
+	data, resp, err := fc.send(request)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, resp.Header.Get("ETag"), nil
+}
+
 // ShallowGet performs a http shallow get request for the given path
 func (fc *FClient) ShallowGet(path string) (interface{}, error) {
 	params := map[string]string{"shallow": "true"}
 	return fc.Get(path, params)
 }
 
+// Export performs a http get request for the given path in export
+// format, which includes priorities as .priority and wraps primitive
+// values with a priority in {".value": v, ".priority": p}
+func (fc *FClient) Export(path string) (interface{}, error) {
+	params := map[string]string{"format": "export"}
+	return fc.Get(path, params)
+}
+
+// FStore Write Operations
+// ----------------------------------------------------------------------------
+
+// Set performs a http put request, replacing the data at the given path
+func (fc *FClient) Set(path string, value interface{}) (interface{}, error) {
+	return fc.write("PUT", path, value, nil)
+}
+
+// SetIfMatch performs a conditional http put request that only succeeds
+// if the data at path still has the given ETag, see GetWithETag.
+// Returns ErrETagMismatch otherwise.
+func (fc *FClient) SetIfMatch(path string, value interface{}, etag string) (interface{}, error) {
+	header := http.Header{}
+	header.Set("if-match", etag)
+
+	return fc.write("PUT", path, value, header)
+}
+
+// Update performs a http patch request, merging the given children
+// into the data at the given path
+func (fc *FClient) Update(path string, value map[string]interface{}) (interface{}, error) {
+	return fc.write("PATCH", path, value, nil)
+}
+
+// SetPriority performs a http put request replacing the priority of
+// the data at the given path. Pass nil to remove the priority.
+func (fc *FClient) SetPriority(path string, priority interface{}) (interface{}, error) {
+	return fc.write("PUT", joinPath(path, ".priority"), priority, nil)
+}
+
+// Delete performs a http delete request for the given path
+func (fc *FClient) Delete(path string) error {
+	_, err := fc.write("DELETE", path, nil, nil)
+	return err
+}
+
+// DeleteIfMatch performs a conditional http delete request,
+// see SetIfMatch
+func (fc *FClient) DeleteIfMatch(path string, etag string) error {
+	header := http.Header{}
+	header.Set("if-match", etag)
+
+	_, err := fc.write("DELETE", path, nil, header)
+	return err
+}
+
+// Security Rules
+// ----------------------------------------------------------------------------
+
+// rulesPath is where firebase exposes the database's security rules
+const rulesPath = ".settings/rules"
+
+// GetRules downloads the database's security rules. The rules are
+// returned as is since they may contain comments.
+func (fc *FClient) GetRules() ([]byte, error) {
+	p, err := fc.buildURL(rulesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest("GET", p, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, _, err := fc.admin().sendRaw(request)
+	return raw, err
+}
+
+// SetRules uploads rules, replacing the database's security rules
+func (fc *FClient) SetRules(rules []byte) error {
+	_, err := fc.admin().writeRaw("PUT", rulesPath, rules, nil)
+	return err
+}
+
 // Networking
 // ----------------------------------------------------------------------------
 
+// admin returns a copy of the client that uses the service
+// account's access, for requests that must not be impersonated
+func (fc *FClient) admin() *FClient {
+	if fc.AuthOverride == nil {
+		return fc
+	}
+
+	admin := *fc
+	admin.AuthOverride = nil
+	return &admin
+}
+
+// authUID returns the uid requests are made as, "" when
+// using the service account's access
+func (fc *FClient) authUID() string {
+	if fc.AuthOverride == nil {
+		return ""
+	}
+
+	uid, _ := fc.AuthOverride["uid"].(string)
+	return uid
+}
+
+func joinPath(p string, child string) string {
+	if p == "" {
+		return child
+	}
+
+	return p + "/" + child
+}
+
 func (fc *FClient) buildURL(p string) (string, error) {
 	if p != "" {
 		// need to escape p properly here
@@ -97,19 +275,207 @@ func (fc *FClient) buildURL(p string) (string, error) {
 	return fc.FirebaseURL + ".json", nil
 }
 
-func (fc *FClient) do(request *http.Request) (interface{}, error) {
-	resp, err := fc.client.Do(request)
+// write sends a mutating request with value encoded as the JSON body.
+// In dry run mode the request is logged and value is returned as is.
+func (fc *FClient) write(method string, path string, value interface{}, header http.Header) (interface{}, error) {
+	var body []byte
+	if value != nil {
+		var err error
+		body, err = json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	raw, err := fc.writeRaw(method, path, body, header)
 	if err != nil {
 		return nil, err
 	}
 
-	decoder := json.NewDecoder(resp.Body)
+	if fc.DryRun {
+		return value, nil
+	}
+
+	return decodeJSON(raw)
+}
 
-	var b interface{}
-	err = decoder.Decode(&b)
+// writeRaw sends a mutating request with body as is and returns
+// the raw response. In dry run mode the request is only logged.
+func (fc *FClient) writeRaw(method string, path string, body []byte, header http.Header) ([]byte, error) {
+	if fc.ReadOnly {
+		return nil, ErrReadOnly
+	}
+
+	p, err := fc.buildURL(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if fc.DryRun {
+		if body == nil {
+			fc.logf("dry-run: %s %s\n", method, p)
+		} else {
+			fc.logf("dry-run: %s %s %s\n", method, p, body)
+		}
+		return nil, nil
+	}
+
+	request, err := http.NewRequest(method, p, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range header {
+		request.Header[key] = values
+	}
+
+	raw, resp, err := fc.sendRaw(request)
+
+	// the request was sent either way, failing to audit it must
+	// not turn a successful write into an error
+	if auditErr := fc.audit(method, path, body, resp); auditErr != nil {
+		fc.warnf("warning: %s %s was sent but not audited: %s\n", method, path, auditErr)
+	}
+
+	return raw, err
+}
+
+// audit records a sent write request in the audit log
+func (fc *FClient) audit(method string, path string, body []byte, resp *http.Response) error {
+	if fc.Audit == nil {
+		return nil
+	}
+
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+
+	entry := AuditEntry{
+		Time:        time.Now().UTC(),
+		Account:     fc.Account,
+		AuthUID:     fc.authUID(),
+		Database:    fc.FirebaseURL,
+		Method:      method,
+		Path:        path,
+		PayloadHash: payloadHash(body),
+		Status:      status,
+	}
+
+	if err := fc.Audit.Record(entry); err != nil {
+		return fmt.Errorf("audit: %s", err)
+	}
+
+	return nil
+}
+
+func (fc *FClient) do(request *http.Request) (interface{}, error) {
+	data, _, err := fc.send(request)
+	return data, err
+}
+
+// send performs the request and decodes the JSON response.
+// The response is returned alongside the data for its status and
+// headers, it is nil if the request could not be sent.
+func (fc *FClient) send(request *http.Request) (interface{}, *http.Response, error) {
+	raw, resp, err := fc.sendRaw(request)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	data, err := decodeJSON(raw)
+	return data, resp, err
+}
+
+// sendRaw performs the request and returns the response body as is.
+// Unsuccessful responses are turned into errors.
+func (fc *FClient) sendRaw(request *http.Request) ([]byte, *http.Response, error) {
+	if fc.ReadOnly && request.Method != "GET" {
+		return nil, nil, ErrReadOnly
+	}
+
+	if fc.AuthOverride != nil {
+		override, err := json.Marshal(fc.AuthOverride)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		q := request.URL.Query()
+		q.Set("auth_variable_override", string(override))
+		request.URL.RawQuery = q.Encode()
+	}
+
+	client := fc.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(request)
 	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return nil, resp, ErrETagMismatch
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := decodeJSON(raw)
+		return nil, resp, responseError(resp.StatusCode, data)
+	}
+
+	return raw, resp, nil
+}
+
+// decodeJSON decodes a response body, an empty body decodes to nil
+func decodeJSON(raw []byte) (interface{}, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, nil
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
 		return nil, err
 	}
 
-	return b, nil
+	return data, nil
+}
+
+func (fc *FClient) logf(format string, a ...interface{}) {
+	if fc.Log == nil {
+		return
+	}
+
+	fmt.Fprintf(fc.Log, format, a...)
+}
+
+// warnf writes a warning to Warnings, see FClient
+func (fc *FClient) warnf(format string, a ...interface{}) {
+	w := fc.Warnings
+	if w == nil {
+		w = os.Stderr
+	}
+
+	fmt.Fprintf(w, format, a...)
+}
+
+// responseError builds an error from a failed response,
+// using firebase's error message when one is available
+func responseError(statusCode int, data interface{}) error {
+	if m, ok := data.(map[string]interface{}); ok {
+		if message, ok := m["error"].(string); ok {
+			if indexErr := parseIndexError(message); indexErr != nil {
+				return indexErr
+			}
+			return fmt.Errorf("firebase: %s (%d)", message, statusCode)
+		}
+	}
+
+	return fmt.Errorf("firebase: %s", http.StatusText(statusCode))
 }
@@ -0,0 +1,134 @@
+package fuego_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sneakybueno/fli/fuego"
+)
+
+func TestDryRunLogsWrites(t *testing.T) {
+	var log bytes.Buffer
+	fClient := &fuego.FClient{
+		FirebaseURL: firebaseTestingURL,
+		DryRun:      true,
+		Log:         &log,
+	}
+
+	_, err := fClient.Set("users/bueno", map[string]interface{}{"name": "bueno"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	err = fClient.Delete("users/corgi")
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	expected := []string{
+		`dry-run: PUT https://go-fli.firebaseio.com/users/bueno.json {"name":"bueno"}`,
+		`dry-run: DELETE https://go-fli.firebaseio.com/users/corgi.json`,
+	}
+
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d log lines, got %d: %q", len(expected), len(lines), lines)
+	}
+
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], lines[i])
+		}
+	}
+}
+
+func TestReadOnlyRejectsWrites(t *testing.T) {
+	var log bytes.Buffer
+	fClient := &fuego.FClient{
+		FirebaseURL: firebaseTestingURL,
+		DryRun:      true,
+		ReadOnly:    true,
+		Log:         &log,
+	}
+
+	_, err := fClient.Set("users/bueno", "bueno")
+	if err != fuego.ErrReadOnly {
+		t.Errorf("Expected %s, got %v", fuego.ErrReadOnly, err)
+	}
+
+	_, err = fClient.Update("users/bueno", map[string]interface{}{"name": "bueno"})
+	if err != fuego.ErrReadOnly {
+		t.Errorf("Expected %s, got %v", fuego.ErrReadOnly, err)
+	}
+
+	err = fClient.Delete("users/bueno")
+	if err != fuego.ErrReadOnly {
+		t.Errorf("Expected %s, got %v", fuego.ErrReadOnly, err)
+	}
+
+	if log.Len() > 0 {
+		t.Errorf("Expected nothing to be logged, got %s", log.String())
+	}
+}
+
+func TestConditionalWrites(t *testing.T) {
+	etag := "etag-1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			if r.Header.Get("X-Firebase-ETag") == "true" {
+				w.Header().Set("ETag", etag)
+			}
+			fmt.Fprint(w, `"bueno"`)
+		case "PUT":
+			if r.Header.Get("if-match") != etag {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				fmt.Fprint(w, `{"error": "ETag mismatch"}`)
+				return
+			}
+			io.Copy(w, r.Body)
+		}
+	}))
+	defer server.Close()
+
+	fClient := &fuego.FClient{FirebaseURL: server.URL + "/"}
+
+	data, tag, err := fClient.GetWithETag("users/bueno")
+	if err != nil || data != "bueno" || tag != etag {
+		t.Fatalf("Unexpected result %v %s %v", data, tag, err)
+	}
+
+	data, err = fClient.SetIfMatch("users/bueno", "corgi", tag)
+	if err != nil || data != "corgi" {
+		t.Errorf("Unexpected result %v %v", data, err)
+	}
+
+	_, err = fClient.SetIfMatch("users/bueno", "corgi", "stale")
+	if err != fuego.ErrETagMismatch {
+		t.Errorf("Expected %s, got %v", fuego.ErrETagMismatch, err)
+	}
+}
+
+func TestServerValues(t *testing.T) {
+	var log bytes.Buffer
+	fClient := &fuego.FClient{
+		FirebaseURL: firebaseTestingURL,
+		DryRun:      true,
+		Log:         &log,
+	}
+
+	fClient.Set("users/bueno/lastSeen", fuego.ServerTimestamp())
+	fClient.Set("counters/visits", fuego.ServerIncrement(2))
+
+	expected := `dry-run: PUT https://go-fli.firebaseio.com/users/bueno/lastSeen.json {".sv":"timestamp"}
+dry-run: PUT https://go-fli.firebaseio.com/counters/visits.json {".sv":{"increment":2}}
+`
+	if log.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, log.String())
+	}
+}
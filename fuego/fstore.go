@@ -1,11 +1,13 @@
 package fuego
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"path"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // FStore struct is used to interact with a firebase
@@ -15,6 +17,31 @@ type FStore struct {
 
 	FirebaseURL      string
 	workingDirectory []string
+
+	// previousDirectory is used by cd -, hasPrevious is
+	// false until the directory is changed once
+	previousDirectory []string
+	hasPrevious       bool
+	// directoryStack is used by Pushd and Popd, top last
+	directoryStack [][]string
+
+	// CdCheck controls whether Cd checks that its target exists
+	CdCheck CdCheckMode
+	// shallow caches shallow gets by path, for Complete
+	// and for checking that a path exists
+	shallow map[string]interface{}
+
+	// Bookmarks are expanded in every path, see Bookmarks.Expand
+	Bookmarks *Bookmarks
+
+	// GlobLimit and GlobConcurrency limit glob expansion,
+	// see Expand. Defaults are used when <= 0.
+	GlobLimit       int
+	GlobConcurrency int
+
+	// Journal records the writes made through the store so
+	// they can be undone, nil disables journaling
+	Journal *Journal
 }
 
 // NewFStore builds a new store based on the 2 passed in params.
@@ -26,21 +53,116 @@ func NewFStore(firebaseURL string, serviceAccountPath string) (*FStore, error) {
 		return nil, err
 	}
 
-	fStore := &FStore{
+	return NewFStoreWithClient(fClient), nil
+}
+
+// NewFStoreWithClient builds a new store that uses an existing client
+func NewFStoreWithClient(fClient *FClient) *FStore {
+	return &FStore{
 		fClient:     fClient,
-		FirebaseURL: firebaseURL,
+		FirebaseURL: fClient.FirebaseURL,
 	}
+}
 
-	return fStore, nil
+// Client returns the FClient used to talk to firebase
+func (fs *FStore) Client() *FClient {
+	return fs.fClient
+}
+
+// Impersonate makes every following request as the user with the
+// given uid, so security rules apply as they would for that user.
+// claims are available to the rules as auth.token.
+func (fs *FStore) Impersonate(uid string, claims map[string]interface{}) {
+	if claims == nil {
+		claims = map[string]interface{}{}
+	}
+
+	fs.fClient.AuthOverride = map[string]interface{}{
+		"uid":   uid,
+		"token": claims,
+	}
+
+	// what was cached was read with another user's access
+	fs.invalidateCaches()
+}
+
+// StopImpersonating returns to the service account's admin access
+func (fs *FStore) StopImpersonating() {
+	fs.fClient.AuthOverride = nil
+	fs.invalidateCaches()
+}
+
+// Impersonating returns the uid of the impersonated user,
+// "" when using the service account's access
+func (fs *FStore) Impersonating() string {
+	if fs.fClient == nil {
+		return ""
+	}
+
+	return fs.fClient.authUID()
 }
 
 // FStore Directory Commands
 // ----------------------------------------------------------------------------
 
+// CdCheckMode controls whether Cd checks that its target exists.
+// When checking, a target that can't be read, e.g. because of the
+// security rules, changes directory and Cd returns a *CdCheckError.
+type CdCheckMode int
+
+const (
+	// CdCheckOff never checks, Cd never fails
+	CdCheckOff CdCheckMode = iota
+	// CdCheckWarn changes directory but returns a *NodeNotFoundError
+	// when the target doesn't exist
+	CdCheckWarn
+	// CdCheckStrict returns a *NodeNotFoundError and stays in the
+	// current directory when the target doesn't exist
+	CdCheckStrict
+)
+
+// ParseCdCheckMode parses "off", "warn" or "strict"
+func ParseCdCheckMode(mode string) (CdCheckMode, error) {
+	switch mode {
+	case "", "off":
+		return CdCheckOff, nil
+	case "warn":
+		return CdCheckWarn, nil
+	case "strict":
+		return CdCheckStrict, nil
+	default:
+		return CdCheckOff, fmt.Errorf("unknown cd check mode: %s", mode)
+	}
+}
+
+// NodeNotFoundError is returned when a path has no data
+type NodeNotFoundError struct {
+	Path string
+}
+
+func (e *NodeNotFoundError) Error() string {
+	return fmt.Sprintf("~/%s: no such node", e.Path)
+}
+
+// CdCheckError is returned by Cd when its target couldn't be checked
+type CdCheckError struct {
+	Path string
+	Err  error
+}
+
+func (e *CdCheckError) Error() string {
+	return fmt.Sprintf("~/%s: couldn't check the node exists: %s", e.Path, e.Err)
+}
+
 // Prompt retuns a string to be displayed
 // as a prompt to the user
 func (fs *FStore) Prompt() string {
-	return "~/" + fs.BuildWorkingDirectoryPath(".") + " > "
+	prompt := "~/" + fs.BuildWorkingDirectoryPath(".") + " > "
+	if uid := fs.Impersonating(); uid != "" {
+		prompt = uid + "@" + prompt
+	}
+
+	return prompt
 }
 
 // Wd (Working directory) returns the path for the "directory"
@@ -61,55 +183,166 @@ func (fs *FStore) FirebaseURLFromWorkingDirectory(path string) string {
 // based on the current working directory.
 // Example: if the cwd = "/users" and path = "1234",
 // it will return users/1234
+// Paths starting with "/" or "~" are absolute and start
+// at the root of the database instead, e.g. ~/users/1234,
+// and paths starting with @name start at a bookmark.
+// See NormalizePath for how the path is cleaned.
 // Pass "" or "." to return working directory path
+// Every command resolves its paths through this function.
 func (fs *FStore) BuildWorkingDirectoryPath(p string) string {
-	if p == "" || p == "." {
-		return path.Join(fs.workingDirectory...)
-	}
+	return strings.Join(fs.resolve(p), "/")
+}
 
-	wd := fs.workingDirectory[:]
+// resolve expands bookmarks in p and returns the
+// normalized components of the resulting path
+func (fs *FStore) resolve(p string) []string {
+	return NormalizePath(fs.workingDirectory, fs.Bookmarks.Expand(p))
+}
 
-	components := strings.Split(p, "/")
-	for _, component := range components {
-		if component == ".." {
-			length := len(wd)
-			if length > 0 {
-				wd = wd[:length-1]
-			}
-		} else {
-			wd = append(wd, component)
+// Cd (Change directory) emulates the cd command on a
+// terminal. Since firebase is a JSON store and not an actual
+// directory structure, the target is only checked if CdCheck
+// is enabled, see CdCheckMode. Pass "-" to return to the
+// previous directory.
+// Errors checking the target are returned once the directory is
+// changed, only a *NodeNotFoundError in strict mode prevents it.
+func (fs *FStore) Cd(dir string) error {
+	var wd []string
+
+	switch dir {
+	case "":
+		// mimicing zsh behavior, if no arg is passed
+		// return to root directory
+		wd = []string{}
+	case "-":
+		if !fs.hasPrevious {
+			return fmt.Errorf("cd: no previous directory")
 		}
+		wd = fs.previousDirectory
+	default:
+		wd = fs.resolve(dir)
+	}
+
+	err := fs.checkDirectory(wd)
+	if fs.blocksCd(err) {
+		return err
 	}
 
-	return path.Join(wd...)
+	fs.changeDirectory(wd)
+	return err
 }
 
-// Cd (Change directory) emulates the cd command on a
-// terminal. One major difference, the Cd command will never fail
-// since firebase is a JSON store and not an actual directory
-// structure.
-func (fs *FStore) Cd(dir string) {
-	// mimicing zsh behavior, if no arg is passed
-	// return to root directory
-	if dir == "" {
-		fs.workingDirectory = []string{}
-		return
-	}
-
-	components := strings.Split(dir, "/")
-	for _, component := range components {
-		if component == ".." {
-			length := len(fs.workingDirectory)
-			if length > 0 {
-				fs.workingDirectory = fs.workingDirectory[:length-1]
-			}
-		} else {
-			fs.workingDirectory = append(fs.workingDirectory, component)
+// blocksCd reports whether err returned by checkDirectory
+// prevents changing directory
+func (fs *FStore) blocksCd(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	switch err.(type) {
+	case *NodeNotFoundError:
+		return fs.CdCheck == CdCheckStrict
+	case *CdCheckError:
+		return false
+	}
+
+	return true
+}
+
+// changeDirectory sets the working directory to wd,
+// remembering the current one for cd -
+func (fs *FStore) changeDirectory(wd []string) {
+	fs.previousDirectory = fs.workingDirectory
+	fs.hasPrevious = true
+	fs.workingDirectory = wd
+}
+
+// Pushd changes directory like Cd and pushes the current directory
+// onto the directory stack. Pass "" to swap the current directory
+// with the top of the stack.
+func (fs *FStore) Pushd(dir string) error {
+	current := fs.workingDirectory
+
+	swap := dir == ""
+	if swap {
+		if len(fs.directoryStack) == 0 {
+			return fmt.Errorf("pushd: directory stack empty")
 		}
+
+		dir = "~/" + strings.Join(fs.directoryStack[len(fs.directoryStack)-1], "/")
+	}
+
+	err := fs.Cd(dir)
+	if fs.blocksCd(err) {
+		return err
+	}
+
+	// the top is only removed once the directory changed
+	if swap {
+		fs.directoryStack = fs.directoryStack[:len(fs.directoryStack)-1]
 	}
+
+	fs.directoryStack = append(fs.directoryStack, current)
+	return err
+}
+
+// Popd changes directory to the top of the directory
+// stack and removes it from the stack
+func (fs *FStore) Popd() error {
+	if len(fs.directoryStack) == 0 {
+		return fmt.Errorf("popd: directory stack empty")
+	}
+
+	top := len(fs.directoryStack) - 1
+	fs.changeDirectory(fs.directoryStack[top])
+	fs.directoryStack = fs.directoryStack[:top]
+
+	return nil
 }
 
-// Ls does a thing
+// Dirs returns the working directory followed by
+// the directory stack, most recently pushed first
+func (fs *FStore) Dirs() []string {
+	dirs := []string{"~/" + fs.Wd()}
+	for i := len(fs.directoryStack) - 1; i >= 0; i-- {
+		dirs = append(dirs, "~/"+strings.Join(fs.directoryStack[i], "/"))
+	}
+
+	return dirs
+}
+
+// checkDirectory returns a *NodeNotFoundError if CdCheck is enabled
+// and wd doesn't exist, or a *CdCheckError if wd couldn't be read.
+// wd is read with a shallow get, which is cached until the next write.
+// A cached missing node is read again since it may have been created
+// by someone else since.
+func (fs *FStore) checkDirectory(wd []string) error {
+	if fs.CdCheck == CdCheckOff || len(wd) == 0 {
+		return nil
+	}
+
+	path := strings.Join(wd, "/")
+	_, cached := fs.shallow[path]
+
+	data, err := fs.shallowGet(path)
+	if err == nil && data == nil && cached {
+		delete(fs.shallow, path)
+		data, err = fs.shallowGet(path)
+	}
+
+	if err != nil {
+		return &CdCheckError{Path: path, Err: err}
+	}
+
+	if data == nil {
+		return &NodeNotFoundError{Path: path}
+	}
+
+	return nil
+}
+
+// Ls lists the keys of the children at p, one per line and sorted,
+// or the value at p if it isn't an object
 func (fs *FStore) Ls(p string) (string, error) {
 	path := fs.BuildWorkingDirectoryPath(p)
 	data, err := fs.fClient.ShallowGet(path)
@@ -120,6 +353,332 @@ func (fs *FStore) Ls(p string) (string, error) {
 	return firebaseDataToString(data)
 }
 
+// List returns the sorted keys of the children at p, or nil
+// keys and the value at p if it isn't an object
+func (fs *FStore) List(p string) ([]string, interface{}, error) {
+	path := fs.BuildWorkingDirectoryPath(p)
+	data, err := fs.fClient.ShallowGet(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if m, ok := data.(map[string]interface{}); ok {
+		return sortedKeys(m), nil, nil
+	}
+
+	return nil, data, nil
+}
+
+// LsPriority is like Ls but lists each key alongside its priority.
+// This reads the full data at p, not just the keys.
+func (fs *FStore) LsPriority(p string) (string, error) {
+	path := fs.BuildWorkingDirectoryPath(p)
+	data, err := fs.fClient.Export(path)
+	if err != nil {
+		return "", err
+	}
+
+	m, ok := data.(map[string]interface{})
+	if !ok {
+		return dataToString(stripPriority(data))
+	}
+
+	if value, ok := m[".value"]; ok {
+		return dataToString(value)
+	}
+
+	keys := sortedKeys(m)
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		priority := "-"
+		if child, ok := m[key].(map[string]interface{}); ok {
+			if p, ok := child[".priority"]; ok {
+				priority = JSONString(p)
+			}
+		}
+
+		lines = append(lines, key+"\t"+priority)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// Get returns the data at p (relative to the working directory),
+// in export format including priorities when withPriority is set
+func (fs *FStore) Get(p string, withPriority bool) (interface{}, error) {
+	path := fs.BuildWorkingDirectoryPath(p)
+
+	if withPriority {
+		return fs.fClient.Export(path)
+	}
+
+	return fs.fClient.Get(path, nil)
+}
+
+// Cat returns the data at p as indented JSON. When withPriority
+// is true the data is read in export format, including priorities.
+func (fs *FStore) Cat(p string, withPriority bool) (string, error) {
+	data, err := fs.Get(p, withPriority)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// Export returns the data at p in export format as indented
+// JSON, which can be written back with Set to restore priorities
+func (fs *FStore) Export(p string) (string, error) {
+	return fs.Cat(p, true)
+}
+
+// Priority returns the priority of the data at p, nil if it has none
+func (fs *FStore) Priority(p string) (interface{}, error) {
+	path := fs.BuildWorkingDirectoryPath(p)
+	return fs.fClient.Get(joinPath(path, ".priority"), nil)
+}
+
+// FStore Write Commands
+// ----------------------------------------------------------------------------
+
+// Set replaces the data at p (relative to the working directory)
+// with value
+func (fs *FStore) Set(p string, value interface{}) error {
+	path := fs.BuildWorkingDirectoryPath(p)
+	return fs.set(path, value)
+}
+
+// Incr atomically adds delta to the number at p (relative to
+// the working directory) using a server value
+func (fs *FStore) Incr(p string, delta float64) error {
+	path := fs.BuildWorkingDirectoryPath(p)
+	return fs.set(path, ServerIncrement(delta))
+}
+
+// SetPriority replaces the priority of the data at p,
+// pass nil to remove it
+func (fs *FStore) SetPriority(p string, priority interface{}) error {
+	path := fs.BuildWorkingDirectoryPath(p)
+	prev, err := fs.journalPrev(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err = fs.fClient.SetPriority(path, priority); err != nil {
+		return err
+	}
+
+	if fs.Journal == nil || fs.fClient.DryRun {
+		return nil
+	}
+
+	value, err := fs.fClient.Get(path, nil)
+	if err != nil {
+		return err
+	}
+
+	return fs.journal(path, prev, value)
+}
+
+// Rm deletes the data at p (relative to the working directory).
+// Removing the root of the database is not allowed.
+func (fs *FStore) Rm(p string) error {
+	path := fs.BuildWorkingDirectoryPath(p)
+	if path == "" {
+		return fmt.Errorf("rm: refusing to remove the root of the database")
+	}
+
+	return fs.delete(path)
+}
+
+// Mv moves the data at src to dst by copying it and then
+// deleting src. Both paths are relative to the working directory.
+// Priorities are preserved.
+func (fs *FStore) Mv(src string, dst string) error {
+	srcPath := fs.BuildWorkingDirectoryPath(src)
+	dstPath := fs.BuildWorkingDirectoryPath(dst)
+	if srcPath == "" || dstPath == "" {
+		return fmt.Errorf("mv: refusing to move the root of the database")
+	}
+
+	data, err := fs.fClient.Export(srcPath)
+	if err != nil {
+		return err
+	}
+
+	if data == nil {
+		return fmt.Errorf("mv: %s: no such node", srcPath)
+	}
+
+	if err = fs.set(dstPath, data); err != nil {
+		return err
+	}
+
+	return fs.delete(srcPath)
+}
+
+// Undo restores the values replaced by the last n writes in the
+// journal, newest first, and returns the entries that were undone.
+// Restores are conditional: if a node changed since fli wrote it
+// Undo stops with ErrETagMismatch instead of clobbering the newer value.
+// In dry run mode the journal is walked as if each entry was restored,
+// and is left as is.
+func (fs *FStore) Undo(n int) ([]JournalEntry, error) {
+	if fs.Journal == nil {
+		return nil, fmt.Errorf("undo: journaling is disabled")
+	}
+
+	fs.invalidateCaches()
+
+	// in dry run mode nothing is restored, so the values the
+	// entries already undone would have restored are tracked
+	dryRun := map[string]interface{}{}
+
+	var undone []JournalEntry
+	for _, entry := range fs.Journal.Recent(n) {
+		current, etag, err := fs.fClient.admin().GetWithETag(entry.Path)
+		if err != nil {
+			return undone, err
+		}
+
+		if value, ok := dryRun[entry.Path]; ok && fs.fClient.DryRun {
+			current = value
+		}
+
+		if !sameJSON(current, entry.Value) {
+			return undone, fmt.Errorf("undo: ~/%s: %s", entry.Path, ErrETagMismatch)
+		}
+
+		if entry.Prev == nil {
+			err = fs.fClient.DeleteIfMatch(entry.Path, etag)
+		} else {
+			_, err = fs.fClient.SetIfMatch(entry.Path, entry.Prev, etag)
+		}
+
+		if err != nil {
+			return undone, fmt.Errorf("undo: ~/%s: %s", entry.Path, err)
+		}
+
+		undone = append(undone, entry)
+
+		// nothing was restored, keep the entry around
+		if fs.fClient.DryRun {
+			dryRun[entry.Path] = entry.Prev
+			continue
+		}
+
+		if err = fs.Journal.Pop(); err != nil {
+			return undone, err
+		}
+	}
+
+	return undone, nil
+}
+
+// invalidateCaches clears the caches that writes can make stale
+func (fs *FStore) invalidateCaches() {
+	fs.shallow = nil
+}
+
+// set writes value to the absolute path, recording the write
+// in the journal
+func (fs *FStore) set(path string, value interface{}) error {
+	prev, err := fs.journalPrev(path)
+	if err != nil {
+		return err
+	}
+
+	written, err := fs.fClient.Set(path, value)
+	if err != nil {
+		return err
+	}
+
+	return fs.journal(path, prev, stripPriority(written))
+}
+
+// delete removes the absolute path, recording the write
+// in the journal
+func (fs *FStore) delete(path string) error {
+	prev, err := fs.journalPrev(path)
+	if err != nil {
+		return err
+	}
+
+	if err = fs.fClient.Delete(path); err != nil {
+		return err
+	}
+
+	return fs.journal(path, prev, nil)
+}
+
+// journalPrev reads the current value at path if it's going to be
+// journaled. Export format is used so undo restores priorities.
+func (fs *FStore) journalPrev(path string) (interface{}, error) {
+	if fs.Journal == nil || fs.fClient.DryRun {
+		return nil, nil
+	}
+
+	// the journal records what was there, not what the
+	// impersonated user is allowed to read
+	return fs.fClient.admin().Export(path)
+}
+
+func (fs *FStore) journal(path string, prev interface{}, value interface{}) error {
+	fs.invalidateCaches()
+
+	if fs.Journal == nil || fs.fClient.DryRun {
+		return nil
+	}
+
+	entry := JournalEntry{
+		Time:  time.Now(),
+		Path:  path,
+		Prev:  prev,
+		Value: value,
+	}
+
+	return fs.Journal.Add(entry)
+}
+
+// FStore Security Rules
+// ----------------------------------------------------------------------------
+
+// Rules returns the database's security rules as is, including comments
+func (fs *FStore) Rules() (string, error) {
+	rules, err := fs.fClient.GetRules()
+	if err != nil {
+		return "", err
+	}
+
+	return string(rules), nil
+}
+
+// SetRules validates rules and uploads them, replacing
+// the database's security rules
+func (fs *FStore) SetRules(rules string) error {
+	if _, err := ParseRules([]byte(rules)); err != nil {
+		return err
+	}
+
+	return fs.fClient.SetRules([]byte(rules))
+}
+
+// RulesDiff returns a unified diff between the database's
+// security rules and rules, "" if they are the same
+func (fs *FStore) RulesDiff(rules string) (string, error) {
+	remote, err := fs.Rules()
+	if err != nil {
+		return "", err
+	}
+
+	return DiffLines(remote, rules, fs.FirebaseURL+rulesPath+".json", "local"), nil
+}
+
 // Search looks for any firebase objects that match for key and value
 // Add wildcard support when key == *
 func (fs *FStore) Search(objectPath string, key string, value interface{}) (string, error) {
@@ -215,6 +774,67 @@ func (fs *FStore) IndexedSearch(objectPath string, key string, value interface{}
 // Private utilities
 // ----------------------------------------------------------------------------
 
+// stripPriority removes the priorities from data in export format
+func stripPriority(data interface{}) interface{} {
+	m, ok := data.(map[string]interface{})
+	if !ok {
+		return data
+	}
+
+	if value, ok := m[".value"]; ok {
+		return value
+	}
+
+	stripped := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		if key == ".priority" {
+			continue
+		}
+		stripped[key] = stripPriority(value)
+	}
+
+	return stripped
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		if strings.HasPrefix(key, ".") {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// JSONString encodes value as JSON for display
+func JSONString(value interface{}) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+
+	return string(b)
+}
+
+// sameJSON compares a and b by their JSON encodings,
+// which have sorted keys
+func sameJSON(a interface{}, b interface{}) bool {
+	x, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+
+	y, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(x, y)
+}
+
 func dataToString(data interface{}) (string, error) {
 	switch v := data.(type) {
 	case int:
@@ -245,11 +865,7 @@ func firebaseDataToString(data interface{}) (string, error) {
 	case string:
 		return v, nil
 	case map[string]interface{}:
-		keys := make([]string, 0, len(v))
-		for k := range v {
-			keys = append(keys, k)
-		}
-		return strings.Join(keys, "\t"), nil
+		return strings.Join(sortedKeys(v), "\n"), nil
 	default:
 		return "", fmt.Errorf("Error: Unsupported type %+v ", data)
 	}
@@ -1,6 +1,10 @@
 package fuego_test
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/sneakybueno/fli/fuego"
@@ -56,3 +60,391 @@ func TestDirectoryCommands(t *testing.T) {
 		t.Errorf("Expected %s, got %s", expected, wd)
 	}
 }
+
+func TestCdNormalizesPaths(t *testing.T) {
+	fStore := &fuego.FStore{
+		FirebaseURL: firebaseTestingURL,
+	}
+
+	fStore.Cd("users/")
+	fStore.Cd("./bueno")
+	fStore.Cd(".")
+	fStore.Cd("dev//")
+	fStore.Cd("..")
+
+	expected := "users/bueno"
+	wd := fStore.Wd()
+	if wd != expected {
+		t.Errorf("Expected %s, got %s", expected, wd)
+	}
+
+	fStore.Cd("../..")
+	expected = ""
+	wd = fStore.Wd()
+	if wd != expected {
+		t.Errorf("Expected %s, got %s", expected, wd)
+	}
+}
+
+func TestCdCheck(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/.json":
+			t.Errorf("Expected the root not to be read")
+		case "/users.json":
+			fmt.Fprint(w, `{"bueno": true}`)
+		case "/broken.json":
+			http.Error(w, `{"error": "Permission denied"}`, http.StatusUnauthorized)
+		default:
+			fmt.Fprint(w, `null`)
+		}
+	}))
+	defer server.Close()
+
+	fStore := fuego.NewFStoreWithClient(&fuego.FClient{FirebaseURL: server.URL + "/"})
+	fStore.CdCheck = fuego.CdCheckStrict
+
+	if err := fStore.Cd("users"); err != nil {
+		t.Errorf("Expected no error, got %s", err)
+	}
+
+	err := fStore.Cd("../usres")
+	if _, ok := err.(*fuego.NodeNotFoundError); !ok {
+		t.Errorf("Expected a NodeNotFoundError, got %v", err)
+	}
+
+	if wd := fStore.Wd(); wd != "users" {
+		t.Errorf("Expected strict mode to stay in users, got %s", wd)
+	}
+
+	// only the targets are read, users is cached
+	fStore.Cd("~")
+	fStore.Cd("users")
+	if requests != 2 {
+		t.Errorf("Expected existence checks to be cached, got %d requests", requests)
+	}
+
+	fStore.CdCheck = fuego.CdCheckWarn
+	err = fStore.Cd("~/usres")
+	if _, ok := err.(*fuego.NodeNotFoundError); !ok {
+		t.Errorf("Expected a NodeNotFoundError, got %v", err)
+	}
+
+	if wd := fStore.Wd(); wd != "usres" {
+		t.Errorf("Expected warn mode to change directory, got %s", wd)
+	}
+
+	// request errors never prevent changing directory
+	for _, mode := range []fuego.CdCheckMode{fuego.CdCheckWarn, fuego.CdCheckStrict} {
+		fStore.CdCheck = mode
+		fStore.Cd("~")
+
+		err = fStore.Cd("broken")
+		if _, ok := err.(*fuego.CdCheckError); !ok {
+			t.Errorf("Expected a CdCheckError, got %v", err)
+		}
+
+		if wd := fStore.Wd(); wd != "broken" {
+			t.Errorf("Expected request errors to change directory, got %s", wd)
+		}
+	}
+}
+
+func TestCdCheckRefetchesMissingNodes(t *testing.T) {
+	created := false
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/users.json" && created {
+			fmt.Fprint(w, `{"bueno": true}`)
+			return
+		}
+		fmt.Fprint(w, `null`)
+	}))
+	defer server.Close()
+
+	fStore := fuego.NewFStoreWithClient(&fuego.FClient{FirebaseURL: server.URL + "/"})
+	fStore.CdCheck = fuego.CdCheckStrict
+
+	if _, ok := fStore.Cd("users").(*fuego.NodeNotFoundError); !ok {
+		t.Errorf("Expected a NodeNotFoundError")
+	}
+
+	if requests != 1 {
+		t.Errorf("Expected a single request, got %d", requests)
+	}
+
+	// created by someone else
+	created = true
+	if err := fStore.Cd("users"); err != nil {
+		t.Errorf("Expected the node to be read again, got %s", err)
+	}
+}
+
+func TestPushdStrict(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/users.json" {
+			fmt.Fprint(w, `{"bueno": true}`)
+			return
+		}
+		fmt.Fprint(w, `null`)
+	}))
+	defer server.Close()
+
+	fStore := fuego.NewFStoreWithClient(&fuego.FClient{FirebaseURL: server.URL + "/"})
+	fStore.Pushd("~/orders")
+	fStore.Pushd("~/users")
+
+	fStore.CdCheck = fuego.CdCheckStrict
+	if err := fStore.Pushd(""); err == nil {
+		t.Errorf("Expected an error for a missing directory")
+	}
+
+	expected := "~/users ~/orders ~/"
+	if dirs := strings.Join(fStore.Dirs(), " "); dirs != expected {
+		t.Errorf("Expected the stack to be left as is, %s, got %s", expected, dirs)
+	}
+}
+
+func TestDirectoryStack(t *testing.T) {
+	fStore := &fuego.FStore{
+		FirebaseURL: firebaseTestingURL,
+	}
+
+	if err := fStore.Cd("-"); err == nil {
+		t.Errorf("Expected an error without a previous directory")
+	}
+
+	fStore.Cd("users/bueno")
+	fStore.Cd("~/orders")
+	fStore.Cd("-")
+	if wd := fStore.Wd(); wd != "users/bueno" {
+		t.Errorf("Expected users/bueno, got %s", wd)
+	}
+
+	fStore.Cd("-")
+	if wd := fStore.Wd(); wd != "orders" {
+		t.Errorf("Expected orders, got %s", wd)
+	}
+
+	fStore.Pushd("~/users")
+	fStore.Pushd("corgi")
+
+	expected := "~/users/corgi ~/users ~/orders"
+	if dirs := strings.Join(fStore.Dirs(), " "); dirs != expected {
+		t.Errorf("Expected %s, got %s", expected, dirs)
+	}
+
+	fStore.Pushd("")
+	expected = "~/users ~/users/corgi ~/orders"
+	if dirs := strings.Join(fStore.Dirs(), " "); dirs != expected {
+		t.Errorf("Expected %s, got %s", expected, dirs)
+	}
+
+	fStore.Popd()
+	fStore.Popd()
+	if wd := fStore.Wd(); wd != "orders" {
+		t.Errorf("Expected orders, got %s", wd)
+	}
+
+	if err := fStore.Popd(); err == nil {
+		t.Errorf("Expected an error for an empty stack")
+	}
+}
+
+func TestAbsolutePaths(t *testing.T) {
+	fStore := &fuego.FStore{
+		FirebaseURL: firebaseTestingURL,
+	}
+
+	fStore.Cd("users/bueno")
+
+	tests := map[string]string{
+		"dev":           "users/bueno/dev",
+		"~":             "",
+		"~/":            "",
+		"/":             "",
+		"~/orders/1234": "orders/1234",
+		"/orders/1234":  "orders/1234",
+		"~/orders/..":   "",
+	}
+
+	for given, expected := range tests {
+		p := fStore.BuildWorkingDirectoryPath(given)
+		if p != expected {
+			t.Errorf("%s: Expected %s, got %s", given, expected, p)
+		}
+	}
+
+	fStore.Cd("~/orders")
+	expected := "orders"
+	wd := fStore.Wd()
+	if wd != expected {
+		t.Errorf("Expected %s, got %s", expected, wd)
+	}
+
+	fStore.Cd("/users/corgi")
+	expected = "users/corgi"
+	wd = fStore.Wd()
+	if wd != expected {
+		t.Errorf("Expected %s, got %s", expected, wd)
+	}
+
+	fStore.Cd("~")
+	expected = ""
+	wd = fStore.Wd()
+	if wd != expected {
+		t.Errorf("Expected %s, got %s", expected, wd)
+	}
+}
+
+func TestPriorities(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "export" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		fmt.Fprint(w, `{"bueno": {".priority": 2, "name": "bueno"}, "corgi": {".value": "corgi", ".priority": "a"}, "pug": "pug"}`)
+	}))
+	defer server.Close()
+
+	fStore := fuego.NewFStoreWithClient(&fuego.FClient{FirebaseURL: server.URL + "/"})
+
+	ls, err := fStore.LsPriority("users")
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	expected := "bueno\t2\ncorgi\t\"a\"\npug\t-"
+	if ls != expected {
+		t.Errorf("Expected %q, got %q", expected, ls)
+	}
+}
+
+func TestUndoDryRun(t *testing.T) {
+	data := map[string]string{"/users/a.json": `"v2"`, "/users/b.json": `"x"`}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("Expected no writes in dry run mode, got %s %s", r.Method, r.URL.Path)
+		}
+
+		w.Header().Set("ETag", "etag")
+		fmt.Fprint(w, data[r.URL.Path])
+	}))
+	defer server.Close()
+
+	fClient := &fuego.FClient{FirebaseURL: server.URL + "/", DryRun: true}
+	fStore := fuego.NewFStoreWithClient(fClient)
+	fStore.Journal, _ = fuego.OpenJournal("")
+
+	fStore.Journal.Add(fuego.JournalEntry{Path: "users/a", Prev: nil, Value: "v1"})
+	fStore.Journal.Add(fuego.JournalEntry{Path: "users/a", Prev: "v1", Value: "v2"})
+	fStore.Journal.Add(fuego.JournalEntry{Path: "users/b", Prev: nil, Value: "x"})
+
+	undone, err := fStore.Undo(3)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	if len(undone) != 3 {
+		t.Errorf("Expected 3 entries undone, got %+v", undone)
+	}
+
+	if len(fStore.Journal.Entries()) != 3 {
+		t.Errorf("Expected the journal to be left as is, got %+v", fStore.Journal.Entries())
+	}
+}
+
+func TestAuthOverride(t *testing.T) {
+	overrides := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Method + " " + r.URL.Path
+		if r.URL.Query().Get("format") == "export" {
+			key += "?export"
+		}
+		overrides[key] = r.URL.Query().Get("auth_variable_override")
+		fmt.Fprint(w, `null`)
+	}))
+	defer server.Close()
+
+	fStore := fuego.NewFStoreWithClient(&fuego.FClient{FirebaseURL: server.URL + "/"})
+	fStore.Journal, _ = fuego.OpenJournal("")
+	fStore.Impersonate("bueno", map[string]interface{}{"admin": true})
+
+	if prompt := fStore.Prompt(); prompt != "bueno@~/ > " {
+		t.Errorf("Expected bueno@~/ > , got %s", prompt)
+	}
+
+	fStore.Ls("users")
+	expected := `{"token":{"admin":true},"uid":"bueno"}`
+	if override := overrides["GET /users.json"]; override != expected {
+		t.Errorf("Expected %s, got %s", expected, override)
+	}
+
+	fStore.Set("users/bueno", "name")
+	if override := overrides["PUT /users/bueno.json"]; override != expected {
+		t.Errorf("Expected %s on writes, got %s", expected, override)
+	}
+
+	if override, ok := overrides["GET /users/bueno.json?export"]; !ok || override != "" {
+		t.Errorf("Expected a journal read without override, got %q", override)
+	}
+
+	fStore.Rules()
+	fStore.SetRules(`{"rules": {}}`)
+	for _, key := range []string{"GET /.settings/rules.json", "PUT /.settings/rules.json"} {
+		if override, ok := overrides[key]; !ok || override != "" {
+			t.Errorf("Expected %s without override, got %q", key, override)
+		}
+	}
+
+	fStore.StopImpersonating()
+	fStore.Ls("users")
+	if override := overrides["GET /users.json"]; override != "" {
+		t.Errorf("Expected no override, got %s", override)
+	}
+}
+
+func TestImpersonateClearsCaches(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the impersonated user can't read users and sees no orders
+		if r.URL.Query().Get("auth_variable_override") != "" {
+			if r.URL.Path == "/orders.json" {
+				fmt.Fprint(w, `null`)
+				return
+			}
+			http.Error(w, `{"error": "Permission denied"}`, http.StatusUnauthorized)
+			return
+		}
+
+		fmt.Fprint(w, `{"admin": true, "bueno": true}`)
+	}))
+	defer server.Close()
+
+	fStore := fuego.NewFStoreWithClient(&fuego.FClient{FirebaseURL: server.URL + "/"})
+	if completions := fStore.Complete("users/a"); len(completions) != 1 {
+		t.Fatalf("Expected users/admin/, got %v", completions)
+	}
+
+	fStore.Impersonate("bueno", nil)
+	if completions := fStore.Complete("users/a"); len(completions) != 0 {
+		t.Errorf("Expected no completions as bueno, got %v", completions)
+	}
+
+	if completions := fStore.Complete("orders/"); len(completions) != 0 {
+		t.Errorf("Expected no orders as bueno, got %v", completions)
+	}
+
+	fStore.CdCheck = fuego.CdCheckWarn
+	if _, ok := fStore.Cd("users").(*fuego.CdCheckError); !ok {
+		t.Errorf("Expected the permission error as bueno")
+	}
+
+	fStore.StopImpersonating()
+	if completions := fStore.Complete("~/orders/a"); len(completions) != 1 {
+		t.Errorf("Expected ~/orders/admin/ after su -, got %v", completions)
+	}
+}
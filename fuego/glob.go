@@ -0,0 +1,129 @@
+package fuego
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultGlobLimit is the maximum number of paths a glob expands to
+	DefaultGlobLimit = 1000
+	// DefaultGlobConcurrency is the maximum number of shallow gets
+	// made at the same time while expanding a glob
+	DefaultGlobConcurrency = 8
+)
+
+// hasGlob returns true if p contains any glob
+// wildcards, see path.Match for the syntax
+func hasGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// Expand resolves p (relative to the working directory) and expands
+// any wildcards in it, e.g. users/*/devices, into the matching absolute
+// paths, sorted. Each wildcard level is enumerated with shallow gets.
+// Paths without wildcards are returned as is, even if they don't exist.
+func (fs *FStore) Expand(p string) ([]string, error) {
+	components := fs.resolve(p)
+	if !hasGlob(strings.Join(components, "/")) {
+		return []string{strings.Join(components, "/")}, nil
+	}
+
+	limit := fs.GlobLimit
+	if limit <= 0 {
+		limit = DefaultGlobLimit
+	}
+
+	matches := [][]string{{}}
+	for _, component := range components {
+		if !hasGlob(component) {
+			for i := range matches {
+				matches[i] = append(matches[i], component)
+			}
+			continue
+		}
+
+		if _, err := path.Match(component, ""); err != nil {
+			return nil, fmt.Errorf("glob: %s: %s", component, err)
+		}
+
+		children, err := fs.matchChildren(matches, component)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(children) > limit {
+			return nil, fmt.Errorf("glob: %s matches more than %d paths", p, limit)
+		}
+		matches = children
+	}
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("glob: no matches found: %s", p)
+	}
+
+	paths := make([]string, 0, len(matches))
+	for _, match := range matches {
+		paths = append(paths, strings.Join(match, "/"))
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// matchChildren lists the children of every parent, concurrently,
+// and returns the paths of the children whose keys match pattern
+func (fs *FStore) matchChildren(parents [][]string, pattern string) ([][]string, error) {
+	concurrency := fs.GlobConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultGlobConcurrency
+	}
+
+	keys := make([][]string, len(parents))
+	errs := make([]error, len(parents))
+	semaphore := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, parent := range parents {
+		wg.Add(1)
+		semaphore <- struct{}{}
+
+		go func(i int, parent string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			data, err := fs.fClient.ShallowGet(parent)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			if m, ok := data.(map[string]interface{}); ok {
+				keys[i] = sortedKeys(m)
+			}
+		}(i, strings.Join(parent, "/"))
+	}
+	wg.Wait()
+
+	var children [][]string
+	for i, parent := range parents {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+
+		for _, key := range keys[i] {
+			if ok, _ := path.Match(pattern, key); !ok {
+				continue
+			}
+
+			child := make([]string, len(parent), len(parent)+1)
+			copy(child, parent)
+			children = append(children, append(child, key))
+		}
+	}
+
+	return children, nil
+}
@@ -0,0 +1,66 @@
+package fuego_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sneakybueno/fli/fuego"
+)
+
+func TestExpand(t *testing.T) {
+	data := map[string]string{
+		"/.json":                    `{"users": true, "orders": true}`,
+		"/users.json":               `{"bueno": true, "corgi": true, "pug": true}`,
+		"/orders.json":              `{"2023-12": true, "2024-01": true, "2024-02": true}`,
+		"/users/bueno.json":         `{"devices": true}`,
+		"/users/corgi.json":         `{"devices": true, "name": "corgi"}`,
+		"/users/pug.json":           `"pug"`,
+		"/users/bueno/devices.json": `{"phone": true}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body, ok := data[r.URL.Path]; ok {
+			fmt.Fprint(w, body)
+			return
+		}
+		fmt.Fprint(w, `null`)
+	}))
+	defer server.Close()
+
+	fStore := fuego.NewFStoreWithClient(&fuego.FClient{FirebaseURL: server.URL + "/"})
+
+	tests := map[string]string{
+		"users/bueno":       "users/bueno",
+		"users/*":           "users/bueno users/corgi users/pug",
+		"users/*/devices":   "users/bueno/devices users/corgi/devices users/pug/devices",
+		"orders/2024-*":     "orders/2024-01 orders/2024-02",
+		"orders/202[3]-*/x": "orders/2023-12/x",
+		"*/bueno":           "orders/bueno users/bueno",
+		"users/*/devices/*": "users/bueno/devices/phone",
+		"users/?u*":         "users/bueno users/pug",
+	}
+
+	for given, expected := range tests {
+		paths, err := fStore.Expand(given)
+		if err != nil {
+			t.Errorf("%s: Expected no error, got %s", given, err)
+			continue
+		}
+
+		if got := strings.Join(paths, " "); got != expected {
+			t.Errorf("%s: Expected %s, got %s", given, expected, got)
+		}
+	}
+
+	if _, err := fStore.Expand("users/x*"); err == nil {
+		t.Errorf("Expected an error when nothing matches")
+	}
+
+	fStore.GlobLimit = 2
+	if _, err := fStore.Expand("users/*"); err == nil {
+		t.Errorf("Expected an error when the glob limit is exceeded")
+	}
+}
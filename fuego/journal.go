@@ -0,0 +1,154 @@
+package fuego
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// journalSize is the number of writes kept in a journal
+const journalSize = 100
+
+// JournalEntry records a single write made through the FStore
+type JournalEntry struct {
+	Time time.Time `json:"time"`
+	Path string    `json:"path"`
+
+	// Prev is the value before the write, nil if the node did not exist
+	Prev interface{} `json:"prev"`
+	// Value is the value after the write, nil for deletes
+	Value interface{} `json:"value"`
+}
+
+// Journal keeps track of the most recent writes so they can be undone.
+// When opened with a file, entries are persisted as JSON lines.
+type Journal struct {
+	file    string
+	entries []JournalEntry
+}
+
+// OpenJournal loads the journal stored in file, creating it if needed.
+// Pass "" to keep the journal in memory only.
+func OpenJournal(file string) (*Journal, error) {
+	j := &Journal{file: file}
+	if file == "" {
+		return j, nil
+	}
+
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		j.entries = append(j.entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(j.entries) > journalSize {
+		j.entries = j.entries[len(j.entries)-journalSize:]
+		return j, j.save()
+	}
+
+	return j, nil
+}
+
+// Entries returns the journal's entries, oldest first
+func (j *Journal) Entries() []JournalEntry {
+	return j.entries
+}
+
+// Add records a write, bumping the oldest entry once
+// the journal is full
+func (j *Journal) Add(entry JournalEntry) error {
+	j.entries = append(j.entries, entry)
+	if len(j.entries) > journalSize {
+		j.entries = j.entries[len(j.entries)-journalSize:]
+		return j.save()
+	}
+
+	if j.file == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(j.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
+
+// Last returns the most recent entry
+func (j *Journal) Last() (JournalEntry, bool) {
+	if len(j.entries) == 0 {
+		return JournalEntry{}, false
+	}
+
+	return j.entries[len(j.entries)-1], true
+}
+
+// Recent returns the n most recent entries, newest first
+func (j *Journal) Recent(n int) []JournalEntry {
+	if n > len(j.entries) {
+		n = len(j.entries)
+	}
+
+	recent := make([]JournalEntry, 0, n)
+	for i := len(j.entries) - 1; i >= len(j.entries)-n; i-- {
+		recent = append(recent, j.entries[i])
+	}
+
+	return recent
+}
+
+// Pop removes the most recent entry
+func (j *Journal) Pop() error {
+	if len(j.entries) == 0 {
+		return nil
+	}
+
+	j.entries = j.entries[:len(j.entries)-1]
+	return j.save()
+}
+
+// save rewrites the journal file with the current entries
+func (j *Journal) save() error {
+	if j.file == "" {
+		return nil
+	}
+
+	var b []byte
+	for _, entry := range j.entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		b = append(b, line...)
+		b = append(b, '\n')
+	}
+
+	return ioutil.WriteFile(j.file, b, 0600)
+}
@@ -0,0 +1,86 @@
+package fuego_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sneakybueno/fli/fuego"
+)
+
+func TestJournalPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fli-journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "journal.jsonl")
+
+	journal, err := fuego.OpenJournal(file)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	journal.Add(fuego.JournalEntry{Time: time.Now(), Path: "users/bueno", Prev: nil, Value: "bueno"})
+	journal.Add(fuego.JournalEntry{Time: time.Now(), Path: "users/corgi", Prev: "corgi", Value: nil})
+
+	journal, err = fuego.OpenJournal(file)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	entries := journal.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+
+	last, ok := journal.Last()
+	if !ok || last.Path != "users/corgi" || last.Prev != "corgi" || last.Value != nil {
+		t.Errorf("Unexpected last entry %+v", last)
+	}
+
+	if err = journal.Pop(); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	journal, err = fuego.OpenJournal(file)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	last, ok = journal.Last()
+	if !ok || last.Path != "users/bueno" || len(journal.Entries()) != 1 {
+		t.Errorf("Unexpected journal after pop %+v", journal.Entries())
+	}
+}
+
+func TestJournalRecent(t *testing.T) {
+	journal, err := fuego.OpenJournal("")
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	if recent := journal.Recent(2); len(recent) != 0 {
+		t.Errorf("Expected no entries, got %+v", recent)
+	}
+
+	for _, p := range []string{"a", "b", "c"} {
+		journal.Add(fuego.JournalEntry{Time: time.Now(), Path: p})
+	}
+
+	recent := journal.Recent(2)
+	if len(recent) != 2 || recent[0].Path != "c" || recent[1].Path != "b" {
+		t.Errorf("Expected c and b, got %+v", recent)
+	}
+
+	if recent = journal.Recent(5); len(recent) != 3 || recent[2].Path != "a" {
+		t.Errorf("Expected every entry, got %+v", recent)
+	}
+
+	if len(journal.Entries()) != 3 {
+		t.Errorf("Expected Recent to leave the journal as is, got %+v", journal.Entries())
+	}
+}
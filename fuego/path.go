@@ -0,0 +1,41 @@
+package fuego
+
+import "strings"
+
+// NormalizePath resolves p against the working directory components
+// wd and returns the resulting components:
+//   - "." and empty components (from "//" or a trailing "/") are skipped
+//   - ".." moves up a level, stopping at the root
+//   - a leading "/" or "~" makes p absolute, starting at the root
+//
+// wd is never modified.
+func NormalizePath(wd []string, p string) []string {
+	if isAbsolutePath(p) {
+		wd = nil
+		p = strings.TrimPrefix(p, "~")
+	}
+
+	components := make([]string, len(wd), len(wd)+strings.Count(p, "/")+1)
+	copy(components, wd)
+
+	for _, component := range strings.Split(p, "/") {
+		switch component {
+		case "", ".":
+			continue
+		case "..":
+			if len(components) > 0 {
+				components = components[:len(components)-1]
+			}
+		default:
+			components = append(components, component)
+		}
+	}
+
+	return components
+}
+
+// isAbsolutePath returns true for paths starting at the root
+// of the database, i.e. "~", "~/users" or "/users"
+func isAbsolutePath(p string) bool {
+	return p == "~" || strings.HasPrefix(p, "~/") || strings.HasPrefix(p, "/")
+}
@@ -0,0 +1,65 @@
+package fuego_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sneakybueno/fli/fuego"
+)
+
+func TestNormalizePath(t *testing.T) {
+	wd := []string{"users", "bueno"}
+
+	tests := []struct {
+		given    string
+		expected string
+	}{
+		{"", "users/bueno"},
+		{".", "users/bueno"},
+		{"./dev", "users/bueno/dev"},
+		{"dev/", "users/bueno/dev"},
+		{"dev//phone", "users/bueno/dev/phone"},
+		{"dev/./phone/", "users/bueno/dev/phone"},
+		{"..", "users"},
+		{"../..", ""},
+		{"../../../..", ""},
+		{"../corgi/", "users/corgi"},
+		{"/", ""},
+		{"~", ""},
+		{"~/", ""},
+		{"//orders", "orders"},
+		{"/orders/./1234/", "orders/1234"},
+		{"~/orders/../users", "users"},
+		{"~bueno", "users/bueno/~bueno"},
+	}
+
+	for _, test := range tests {
+		components := fuego.NormalizePath(wd, test.given)
+		p := strings.Join(components, "/")
+		if p != test.expected {
+			t.Errorf("%q: Expected %q, got %q", test.given, test.expected, p)
+		}
+
+		for _, component := range components {
+			if component == "" || component == "." || component == ".." {
+				t.Errorf("%q: Unexpected component %q in %q", test.given, component, components)
+			}
+		}
+	}
+
+	if strings.Join(wd, "/") != "users/bueno" {
+		t.Errorf("Expected wd to be unchanged, got %q", wd)
+	}
+}
+
+func TestNormalizePathDoesNotAlias(t *testing.T) {
+	wd := make([]string, 1, 10)
+	wd[0] = "users"
+
+	a := fuego.NormalizePath(wd, "bueno")
+	b := fuego.NormalizePath(wd, "corgi")
+
+	if strings.Join(a, "/") != "users/bueno" || strings.Join(b, "/") != "users/corgi" {
+		t.Errorf("Expected independent paths, got %q and %q", a, b)
+	}
+}
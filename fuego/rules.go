@@ -0,0 +1,327 @@
+package fuego
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var indexErrorRegexp = regexp.MustCompile(`Index not defined, add "\.indexOn": "(.*)", for path "(.*)", to the rules`)
+
+// IndexError is returned when a query orders by a key
+// that isn't indexed in the database's rules
+type IndexError struct {
+	Path string
+	Key  string
+}
+
+func (e *IndexError) Error() string {
+	return fmt.Sprintf("firebase: index not defined for %q at ~/%s", e.Key, e.Path)
+}
+
+// Snippet returns the rules needed to index the key
+func (e *IndexError) Snippet() string {
+	rules := map[string]interface{}{}
+	node, _ := ruleNode(rules, e.Path, true)
+	addIndexOnKey(node, e.Key)
+
+	b, _ := json.MarshalIndent(map[string]interface{}{"rules": rules}, "", "  ")
+	return string(b)
+}
+
+func parseIndexError(message string) *IndexError {
+	matches := indexErrorRegexp.FindStringSubmatch(message)
+	if matches == nil {
+		return nil
+	}
+
+	return &IndexError{
+		Path: strings.Trim(matches[2], "/"),
+		Key:  matches[1],
+	}
+}
+
+// AddIndexOn adds key to the .indexOn rule at path and returns
+// the updated rules. Comments in rules are not preserved.
+// Existing $wildcard rules are used for path's components, see
+// ruleNode, an error is returned when there's no rule to add to.
+func AddIndexOn(rules []byte, path string, key string) ([]byte, error) {
+	parsed, err := ParseRules(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := ruleNode(parsed["rules"].(map[string]interface{}), path, false)
+	if err != nil {
+		return nil, err
+	}
+	addIndexOnKey(node, key)
+
+	b, err := json.MarshalIndent(parsed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
+
+// ruleNode returns the rule at path, matching each component against
+// a key or a $wildcard key, e.g. users/abc/posts matches users/$uid/posts.
+// Missing rules are created when create is set, otherwise only the last
+// component may be missing since a rule for a single user's data can't
+// be told apart from a rule for a list.
+func ruleNode(rules map[string]interface{}, path string, create bool) (map[string]interface{}, error) {
+	var components []string
+	for _, component := range strings.Split(path, "/") {
+		if component != "" {
+			components = append(components, component)
+		}
+	}
+
+	node := rules
+	for i, component := range components {
+		child, ok := node[component].(map[string]interface{})
+		if !ok {
+			child, ok = wildcardRule(node)
+		}
+
+		if !ok {
+			if !create && i < len(components)-1 {
+				return nil, fmt.Errorf("rules: no rule matches ~/%s, add a $wildcard rule for it",
+					strings.Join(components[:i+1], "/"))
+			}
+
+			child = map[string]interface{}{}
+			node[component] = child
+		}
+
+		node = child
+	}
+
+	return node, nil
+}
+
+// wildcardRule returns the $wildcard child of node, if any
+func wildcardRule(node map[string]interface{}) (map[string]interface{}, bool) {
+	for key, value := range node {
+		if child, ok := value.(map[string]interface{}); ok && strings.HasPrefix(key, "$") {
+			return child, true
+		}
+	}
+
+	return nil, false
+}
+
+// addIndexOnKey adds key to node's .indexOn rule
+func addIndexOnKey(node map[string]interface{}, key string) {
+	switch indexOn := node[".indexOn"].(type) {
+	case string:
+		if indexOn != key {
+			node[".indexOn"] = []interface{}{indexOn, key}
+		}
+	case []interface{}:
+		for _, k := range indexOn {
+			if k == key {
+				return
+			}
+		}
+		node[".indexOn"] = append(indexOn, key)
+	default:
+		node[".indexOn"] = []interface{}{key}
+	}
+}
+
+// StripJSONComments replaces // and /* */ comments outside of
+// strings with spaces. Newlines are kept so offsets into the
+// result line up with the original.
+func StripJSONComments(b []byte) []byte {
+	out := make([]byte, len(b))
+	copy(out, b)
+
+	inString := false
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+
+		if inString {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = true
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(out)
+			} else {
+				end += i + 4
+			}
+
+			for ; i < end; i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			i--
+		}
+	}
+
+	return out
+}
+
+// ParseRules validates rules as JSON with comments and returns the
+// decoded rules. Syntax errors include the line and column.
+func ParseRules(rules []byte) (map[string]interface{}, error) {
+	stripped := StripJSONComments(rules)
+
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(stripped, &parsed); err != nil {
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			line, col := lineAndColumn(stripped, syntaxErr.Offset)
+			return nil, fmt.Errorf("rules: line %d, column %d: %s", line, col, syntaxErr)
+		}
+		return nil, fmt.Errorf("rules: %s", err)
+	}
+
+	if _, ok := parsed["rules"].(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("rules: missing top level \"rules\" object")
+	}
+
+	return parsed, nil
+}
+
+func lineAndColumn(b []byte, offset int64) (int, int) {
+	if offset > int64(len(b)) {
+		offset = int64(len(b))
+	}
+
+	before := b[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := int(offset) - bytes.LastIndexByte(before, '\n')
+
+	return line, col
+}
+
+// DiffLines returns a unified diff of the lines in a and b
+// with 3 lines of context, or "" if they are the same
+func DiffLines(a string, b string, aName string, bName string) string {
+	x := splitLines(a)
+	y := splitLines(b)
+
+	// lcs[i][j] is the length of the longest common
+	// subsequence of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type op struct {
+		kind byte
+		line string
+		i, j int
+	}
+
+	var ops []op
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			ops = append(ops, op{' ', x[i], i, j})
+			i++
+			j++
+		case j < len(y) && (i == len(x) || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, op{'+', y[j], i, j})
+			j++
+		default:
+			ops = append(ops, op{'-', x[i], i, j})
+			i++
+		}
+	}
+
+	const context = 3
+
+	var out strings.Builder
+	for start := 0; start < len(ops); {
+		if ops[start].kind == ' ' {
+			start++
+			continue
+		}
+
+		// extend the hunk until there are more than 2*context
+		// unchanged lines in a row
+		end := start
+		for k := start; k < len(ops); k++ {
+			if ops[k].kind != ' ' {
+				end = k + 1
+			} else if k-end >= 2*context {
+				break
+			}
+		}
+
+		from := start - context
+		if from < 0 {
+			from = 0
+		}
+		to := end + context
+		if to > len(ops) {
+			to = len(ops)
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+		}
+
+		aCount, bCount := 0, 0
+		for _, o := range ops[from:to] {
+			if o.kind != '+' {
+				aCount++
+			}
+			if o.kind != '-' {
+				bCount++
+			}
+		}
+
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", ops[from].i+1, aCount, ops[from].j+1, bCount)
+		for _, o := range ops[from:to] {
+			out.WriteByte(o.kind)
+			out.WriteString(o.line)
+			out.WriteByte('\n')
+		}
+
+		start = to
+	}
+
+	return out.String()
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, "\n")
+}
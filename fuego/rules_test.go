@@ -0,0 +1,189 @@
+package fuego_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sneakybueno/fli/fuego"
+)
+
+func TestStripJSONComments(t *testing.T) {
+	given := `{
+  // line comment
+  "rules": { /* block
+  comment */ ".read": "auth != null // not a comment",
+    ".write": false
+  }
+}`
+
+	stripped := string(fuego.StripJSONComments([]byte(given)))
+	if len(stripped) != len(given) {
+		t.Errorf("Expected length %d, got %d", len(given), len(stripped))
+	}
+
+	if strings.Count(stripped, "\n") != strings.Count(given, "\n") {
+		t.Errorf("Expected newlines to be kept, got %q", stripped)
+	}
+
+	if strings.Contains(stripped, "line comment") || strings.Contains(stripped, "block") {
+		t.Errorf("Expected comments to be removed, got %q", stripped)
+	}
+
+	if !strings.Contains(stripped, `"auth != null // not a comment"`) {
+		t.Errorf("Expected strings to be kept, got %q", stripped)
+	}
+
+	if _, err := fuego.ParseRules([]byte(given)); err != nil {
+		t.Errorf("Expected valid rules, got %s", err)
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	_, err := fuego.ParseRules([]byte("{\n  \"rules\": {\n    \".read\": true,\n  }\n}"))
+	if err == nil || !strings.Contains(err.Error(), "line 4") {
+		t.Errorf("Expected a syntax error on line 4, got %v", err)
+	}
+
+	_, err = fuego.ParseRules([]byte(`{".read": true}`))
+	if err == nil {
+		t.Errorf("Expected an error for missing rules")
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	if diff := fuego.DiffLines("a\nb\n", "a\nb\n", "remote", "local"); diff != "" {
+		t.Errorf("Expected no diff, got %q", diff)
+	}
+
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12"
+	b := "1\n2\n3\n4\nfour\n6\n7\n8\n9\n10\n11\n12\n13"
+
+	expected := `--- remote
++++ local
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++four
+ 6
+ 7
+ 8
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`
+
+	if diff := fuego.DiffLines(a, b, "remote", "local"); diff != expected {
+		t.Errorf("Expected %q, got %q", expected, diff)
+	}
+}
+
+func TestIndexErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": "Index not defined, add \".indexOn\": \"name\", for path \"/users\", to the rules"}`)
+	}))
+	defer server.Close()
+
+	fStore := fuego.NewFStoreWithClient(&fuego.FClient{FirebaseURL: server.URL + "/"})
+
+	_, err := fStore.IndexedSearch("users", "name", "bueno")
+	indexErr, ok := err.(*fuego.IndexError)
+	if !ok {
+		t.Fatalf("Expected an IndexError, got %v", err)
+	}
+
+	if indexErr.Path != "users" || indexErr.Key != "name" {
+		t.Errorf("Unexpected IndexError %+v", indexErr)
+	}
+
+	expected := `{
+  "rules": {
+    "users": {
+      ".indexOn": [
+        "name"
+      ]
+    }
+  }
+}`
+	if indexErr.Snippet() != expected {
+		t.Errorf("Expected %s, got %s", expected, indexErr.Snippet())
+	}
+}
+
+func TestAddIndexOn(t *testing.T) {
+	given := `{
+  // comments are dropped
+  "rules": {
+    "users": {".indexOn": "email", ".read": true}
+  }
+}`
+
+	rules, err := fuego.AddIndexOn([]byte(given), "users", "name")
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	rules, err = fuego.AddIndexOn(rules, "users", "name")
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	parsed, err := fuego.ParseRules(rules)
+	if err != nil {
+		t.Fatalf("Expected valid rules, got %s", err)
+	}
+
+	users := parsed["rules"].(map[string]interface{})["users"].(map[string]interface{})
+	indexOn := fmt.Sprint(users[".indexOn"])
+	if indexOn != "[email name]" || users[".read"] != true {
+		t.Errorf("Unexpected rules %s", rules)
+	}
+}
+
+func TestAddIndexOnWildcards(t *testing.T) {
+	given := `{
+  "rules": {
+    "users": {
+      "$uid": {".read": true}
+    }
+  }
+}`
+
+	rules, err := fuego.AddIndexOn([]byte(given), "users/abc/posts", "time")
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	parsed, err := fuego.ParseRules(rules)
+	if err != nil {
+		t.Fatalf("Expected valid rules, got %s", err)
+	}
+
+	users := parsed["rules"].(map[string]interface{})["users"].(map[string]interface{})
+	if _, ok := users["abc"]; ok {
+		t.Errorf("Expected the $uid rule to be used, got %s", rules)
+	}
+
+	posts, ok := users["$uid"].(map[string]interface{})["posts"].(map[string]interface{})
+	if !ok || fmt.Sprint(posts[".indexOn"]) != "[time]" {
+		t.Errorf("Unexpected rules %s", rules)
+	}
+
+	// without a wildcard the index would only cover a single user
+	_, err = fuego.AddIndexOn([]byte(`{"rules": {"users": {}}}`), "users/abc/posts", "time")
+	if err == nil {
+		t.Errorf("Expected an error for a path without a matching rule")
+	}
+
+	// a new list can still be indexed
+	if _, err = fuego.AddIndexOn([]byte(`{"rules": {}}`), "orders", "time"); err != nil {
+		t.Errorf("Expected no error, got %s", err)
+	}
+}
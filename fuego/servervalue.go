@@ -0,0 +1,19 @@
+package fuego
+
+// Server values are placeholders that firebase replaces
+// with a value computed on the server when they are written.
+// See https://firebase.google.com/docs/reference/rest/database#section-server-values
+
+// ServerTimestamp returns a placeholder for the time, in
+// milliseconds since the unix epoch, at which the write happens
+func ServerTimestamp() map[string]interface{} {
+	return map[string]interface{}{".sv": "timestamp"}
+}
+
+// ServerIncrement returns a placeholder that atomically adds
+// delta to the current value. A missing value is treated as 0.
+func ServerIncrement(delta float64) map[string]interface{} {
+	return map[string]interface{}{
+		".sv": map[string]interface{}{"increment": delta},
+	}
+}
@@ -8,12 +8,25 @@ import (
 
 type CommandHandler func(args []string, s *Shell) (string, error)
 
+// Completer returns the tab completion candidates for the last of
+// args, the word being completed. args[0] is the command name.
+type Completer func(args []string) []string
+
 type Command struct {
 	Name    string
 	Handler CommandHandler
+
+	// Usage describes the command's arguments, e.g. "[path] [value]",
+	// and Help what the command does in a line, both shown by help
+	Usage string
+	Help  string
+
+	// Completer completes the command's arguments, the
+	// shell's completer is used when it's nil
+	Completer Completer
 }
 
-type Commands []Command
+type Commands []*Command
 
 func (commands Commands) Len() int {
 	return len(commands)
@@ -27,46 +40,116 @@ func (commands Commands) Swap(i, j int) {
 	commands[i], commands[j] = commands[j], commands[i]
 }
 
+// AddCommand registers a command. The returned command can be
+// used to set optional fields, e.g. its Completer.
 func (s *Shell) AddCommand(name string, handler CommandHandler) *Command {
-	command := Command{
+	command := &Command{
 		Name:    name,
 		Handler: handler,
 	}
+	s.Register(command)
 
-	s.commands = append(s.commands, command)
-	sort.Sort(s.commands)
+	return command
+}
 
-	return &command
+// Register registers commands, e.g. a table of commands
+// shared by several shells
+func (s *Shell) Register(commands ...*Command) {
+	s.commands = append(s.commands, commands...)
+	sort.Sort(s.commands)
 }
 
-func (s *Shell) Process(input string) (string, error) {
-	if len(input) == 0 {
-		return "", nil
+// Positional returns a completer that completes the nth
+// argument with completers[n-1], and nothing past the last one.
+// Flags, words starting with -, don't count as arguments and are
+// left out of the args passed to completers, e.g. cat -p us<TAB>
+// completes the first argument.
+func Positional(completers ...Completer) Completer {
+	return func(args []string) []string {
+		if len(args) < 2 {
+			return nil
+		}
+
+		positional := []string{args[0]}
+		for _, arg := range args[1 : len(args)-1] {
+			if !strings.HasPrefix(arg, "-") {
+				positional = append(positional, arg)
+			}
+		}
+		positional = append(positional, args[len(args)-1])
+
+		n := len(positional) - 1
+		if n < 1 || n > len(completers) || completers[n-1] == nil {
+			return nil
+		}
+
+		return completers[n-1](positional)
 	}
+}
 
-	components := strings.Split(input, " ")
-	commandString := components[0]
+// Words returns a completer that completes the
+// last argument with one of words
+func Words(words ...string) Completer {
+	return func(args []string) []string {
+		return FindTerms(words, args[len(args)-1])
+	}
+}
 
-	command, err := s.FindCommand(commandString)
+// Process runs input and prints the output. Input is split into words
+// with SplitWords and may be a pipeline of commands separated by |,
+// with the output redirected to a file with > or >>, see run.
+// Registered commands take precedence over external ones, a stage
+// named like both, e.g. a command named grep, runs the registered one.
+func (s *Shell) Process(input string) (string, error) {
+	p, err := parsePipeline(input)
 	if err != nil {
-		fmt.Println(err)
-		fmt.Print(s.prompt)
+		s.printError(err)
+		s.printPrompt()
 
 		return "", err
 	}
 
-	out, err := command.Handler(components, s)
+	if len(p.stages) == 0 {
+		s.printPrompt()
+		return "", nil
+	}
+
+	out, err := s.run(p)
 	if err != nil {
-		fmt.Println(err)
-	} else if out != "" {
-		fmt.Println(out)
+		s.printError(err)
 	}
 
-	fmt.Print(s.prompt)
+	s.printPrompt()
 
 	return out, err
 }
 
+// Run runs the command named by args[0] with args and prints its output
+// like Process. args are already split into words, e.g. command line
+// arguments, so they aren't parsed for quotes or pipelines.
+func (s *Shell) Run(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", nil
+	}
+
+	out, err := s.run(&pipeline{stages: [][]string{args}})
+	if err != nil {
+		s.printError(err)
+	}
+
+	return out, err
+}
+
+// CommandNames returns the names of every command, sorted
+func (s *Shell) CommandNames() []string {
+	names := make([]string, 0, len(s.commands))
+	for _, command := range s.commands {
+		names = append(names, command.Name)
+	}
+
+	return names
+}
+
 // Binary search for command based on name
 func (s *Shell) FindCommand(commandName string) (*Command, error) {
 	l := 0
@@ -78,7 +161,7 @@ func (s *Shell) FindCommand(commandName string) (*Command, error) {
 
 		comparison := strings.Compare(commandName, c.Name)
 		if comparison == 0 {
-			return &c, nil
+			return c, nil
 		}
 
 		if comparison < 0 {
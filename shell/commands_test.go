@@ -1,9 +1,11 @@
 package shell_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/sneakybueno/fli/shell"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestFindingCommands(t *testing.T) {
@@ -66,3 +68,154 @@ func TestFindingCommands(t *testing.T) {
 		t.Errorf("Expected no error and command, got: %s", err)
 	}
 }
+
+func TestCommandCompleter(t *testing.T) {
+	s := &shell.Shell{}
+
+	s.AddCommand("cd", nil).Completer = shell.Words("users", "orders")
+	s.AddCommand("ls", nil)
+	s.AddCommand("cat", nil)
+
+	command, err := s.FindCommand("cd")
+	if err != nil {
+		t.Fatalf("Expected no error and command, got: %s", err)
+	}
+
+	if command.Completer == nil {
+		t.Fatalf("Expected cd to keep its completer")
+	}
+
+	candidates := command.Completer([]string{"cd", "us"})
+	if len(candidates) != 1 || candidates[0] != "users" {
+		t.Errorf("Expected [users], got: %v", candidates)
+	}
+
+	command, _ = s.FindCommand("ls")
+	if command.Completer != nil {
+		t.Errorf("Expected ls to have no completer")
+	}
+}
+
+func TestPositionalCompleter(t *testing.T) {
+	completer := shell.Positional(shell.Words("get", "set"), nil, shell.Words("users"))
+
+	assert.Equal(t, []string{"get"}, completer([]string{"rules", "g"}))
+	assert.Empty(t, completer([]string{"rules", "get", "u"}))
+	assert.Equal(t, []string{"users"}, completer([]string{"rules", "get", "x", "u"}))
+	assert.Empty(t, completer([]string{"rules", "get", "x", "users", "u"}))
+
+	// flags don't count as arguments
+	assert.Equal(t, []string{"get"}, completer([]string{"rules", "-p", "g"}))
+	assert.Equal(t, []string{"users"}, completer([]string{"rules", "get", "--all", "x", "u"}))
+
+	var completed []string
+	keys := shell.Positional(nil, func(args []string) []string {
+		completed = args
+		return nil
+	})
+	keys([]string{"find", "-p", "users", "--limit", "n"})
+	assert.Equal(t, []string{"find", "users", "n"}, completed)
+}
+
+func TestScript(t *testing.T) {
+	script := strings.NewReader("# set up\necho a\n\n  echo b c  \nfail\necho d\n")
+
+	s, err := shell.InitWithOptions("> ", shell.Options{Script: script})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.False(t, s.Interactive())
+
+	var echoed []string
+	s.AddCommand("echo", func(args []string, s *shell.Shell) (string, error) {
+		echoed = append(echoed, strings.Join(args[1:], " "))
+		return "", nil
+	})
+	s.AddCommand("fail", func(args []string, s *shell.Shell) (string, error) {
+		_, err := s.ReadLine("are you sure? ")
+		return "", err
+	})
+
+	var errs []error
+	for s.Next() {
+		if _, err := s.Process(s.Input()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	assert.NoError(t, s.Error())
+	assert.Equal(t, []string{"a", "b c", "d"}, echoed)
+	assert.Equal(t, []error{shell.ErrNotInteractive}, errs)
+}
+
+func TestScriptExit(t *testing.T) {
+	script := strings.NewReader("echo a\nexit\necho b\n")
+
+	s, err := shell.InitWithOptions("> ", shell.Options{Script: script})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var echoed []string
+	s.AddCommand("echo", func(args []string, s *shell.Shell) (string, error) {
+		echoed = append(echoed, strings.Join(args[1:], " "))
+		return "", nil
+	})
+
+	for s.Next() {
+		_, err := s.Process(s.Input())
+		assert.NoError(t, err)
+	}
+
+	// exit stops the script instead of exiting the process
+	assert.NoError(t, s.Error())
+	assert.Equal(t, []string{"a"}, echoed)
+}
+
+func TestRunAndHelp(t *testing.T) {
+	s := shell.New("> ")
+	assert.False(t, s.Interactive())
+	assert.False(t, s.Next())
+
+	var ran []string
+	s.Register(&shell.Command{
+		Name:  "set",
+		Usage: "[path] [value]",
+		Help:  "Replace the data at path",
+		Handler: func(args []string, s *shell.Shell) (string, error) {
+			ran = args
+			return "", nil
+		},
+	}, &shell.Command{
+		Name: "pwd",
+		Handler: func(args []string, s *shell.Shell) (string, error) {
+			return "~/", nil
+		},
+	})
+
+	assert.Equal(t, []string{"exit", "help", "pwd", "set"}, s.CommandNames())
+
+	// args are run as is, without splitting or pipelines
+	_, err := s.Run([]string{"set", "users/a", `{"name": "Jane Doe"}`, "|", "wc"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"set", "users/a", `{"name": "Jane Doe"}`, "|", "wc"}, ran)
+
+	_, err = s.Run([]string{"nope"})
+	assert.Error(t, err)
+
+	help, err := s.Run([]string{"help"})
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"exit                 Exit the shell",
+		"help [command]       List the commands, or show a command's usage",
+		"pwd",
+		"set [path] [value]   Replace the data at path",
+	}, "\n"), help)
+
+	help, err = s.Run([]string{"help", "set"})
+	assert.NoError(t, err)
+	assert.Equal(t, "usage: set [path] [value]\n\nReplace the data at path", help)
+
+	_, err = s.Run([]string{"help", "nope"})
+	assert.Error(t, err)
+}
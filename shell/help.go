@@ -0,0 +1,58 @@
+package shell
+
+import (
+	"strings"
+	"text/tabwriter"
+)
+
+// Help lists every command with its usage and help
+func (s *Shell) Help() string {
+	var b strings.Builder
+
+	w := tabwriter.NewWriter(&b, 0, 0, 3, ' ', 0)
+	for _, command := range s.commands {
+		w.Write([]byte(command.Synopsis() + "\t" + command.Help + "\n"))
+	}
+	w.Flush()
+
+	// commands without help are padded with trailing spaces
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Synopsis returns the command's name followed by its usage
+func (command *Command) Synopsis() string {
+	if command.Usage == "" {
+		return command.Name
+	}
+
+	return command.Name + " " + command.Usage
+}
+
+// helpHandler lists the commands, or shows the usage of the named command
+func helpHandler(args []string, s *Shell) (string, error) {
+	if len(args) < 2 {
+		return s.Help(), nil
+	}
+
+	command, err := s.FindCommand(args[1])
+	if err != nil {
+		return "", err
+	}
+
+	help := "usage: " + command.Synopsis()
+	if command.Help != "" {
+		help += "\n\n" + command.Help
+	}
+
+	return help, nil
+}
+
+// commandCompleter completes the last argument with a command name
+func (s *Shell) commandCompleter(args []string) []string {
+	return FindTerms(s.CommandNames(), args[len(args)-1])
+}
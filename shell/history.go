@@ -1,14 +1,29 @@
 package shell
 
 import (
+	"bufio"
 	"container/ring"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
 )
 
+// DefaultHistorySize is the number of cmds kept when no size is given
+const DefaultHistorySize = 50
+
 // CmdHistory keeps track of cmds using a ring buffer
 type CmdHistory struct {
-	max    int
-	cmds   *ring.Ring
+	max int
+	// cmds is the oldest cmd in the ring
+	cmds *ring.Ring
+	// offset is the position of the cmd being browsed with Prev and
+	// Next, from the oldest. It's the ring's length when not browsing.
 	offset int
+
+	// file the history is persisted to, "" keeps it in memory only
+	file string
 }
 
 // InitCmdHistory inits the ring buffer and sets the history's max capacity
@@ -19,64 +34,269 @@ func InitCmdHistory(max int) *CmdHistory {
 	}
 }
 
+// LoadCmdHistory inits the history with the cmds stored in file, one
+// per line, keeping the most recent max. Added cmds are appended to
+// the file. A missing file is created on the first Add.
+func LoadCmdHistory(max int, file string) (*CmdHistory, error) {
+	c := InitCmdHistory(max)
+
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		c.file = file
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		c.Add(scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// set the file once loaded so loading doesn't write to it,
+	// a file that was trimmed is rewritten
+	c.file = file
+	if c.cmds.Len() < countLines(file) {
+		return c, c.save()
+	}
+
+	return c, nil
+}
+
 // All returns a list of all previously added commands
 func (c *CmdHistory) All() []string {
 	cmds := []string{}
-	c.reset()
+	if c.cmds.Len() == 0 {
+		return cmds
+	}
+
+	r := c.cmds
 	for i := 0; i < c.cmds.Len(); i++ {
-		cmds = append(cmds, c.cmds.Value.(string))
-		c.cmds = c.cmds.Next()
+		cmds = append(cmds, r.Value.(string))
+		r = r.Next()
 	}
 	return cmds
 }
 
-// Next iterates "clockwise" around the ring from the current position
-func (c *CmdHistory) Next() string {
-	if c.cmds.Len() == 0 {
-		return ""
+// Next moves to the next newer cmd and returns it. Moving past the newest
+// cmd ends browsing and returns "". Returns false, without moving, when
+// not browsing.
+func (c *CmdHistory) Next() (string, bool) {
+	if !c.Browsing() {
+		return "", false
 	}
-	c.offset = (c.offset + 1) % c.cmds.Len()
-	c.cmds = c.cmds.Next()
-	return c.cmds.Value.(string)
+
+	c.offset++
+	if !c.Browsing() {
+		return "", true
+	}
+
+	return c.cmds.Move(c.offset).Value.(string), true
 }
 
-// Prev iterates "counter-clockwise" around the ring from the current position
-func (c *CmdHistory) Prev() string {
+// Prev moves to the next older cmd and returns it, starting with the
+// newest. Returns false, staying at the oldest cmd, when there is none.
+func (c *CmdHistory) Prev() (string, bool) {
 	if c.cmds.Len() == 0 {
-		return ""
+		return "", false
 	}
-	c.offset -= 1
-	if c.offset < 0 {
-		c.offset = c.cmds.Len()
+
+	if c.offset == 0 {
+		return c.cmds.Value.(string), false
 	}
-	c.cmds = c.cmds.Prev()
-	return c.cmds.Value.(string)
+
+	c.offset--
+	return c.cmds.Move(c.offset).Value.(string), true
+}
+
+// Browsing returns true when a cmd was moved to with Prev,
+// until Next moves past the newest cmd or a cmd is added
+func (c *CmdHistory) Browsing() bool {
+	return c.offset < c.cmds.Len()
+}
+
+// Search returns the position, as numbered by All from 0, of the newest
+// cmd containing query that's older than the cmd at before.
+// Returns -1 when no cmd matches.
+func (c *CmdHistory) Search(query string, before int) int {
+	cmds := c.All()
+	if before > len(cmds) {
+		before = len(cmds)
+	}
+
+	for i := before - 1; i >= 0; i-- {
+		if strings.Contains(cmds[i], query) {
+			return i
+		}
+	}
+
+	return -1
 }
 
 // Add inserts a new cmd into the ring. If the ring is not empty, the new cmd
 // is the predecessor of the last cmd that was most recently inserted. If the
 // ring's capacity is reached, the oldest cmd is bumped.
-func (c *CmdHistory) Add(cmd string) {
+// Cmds starting with a space and repeats of the last cmd are skipped.
+func (c *CmdHistory) Add(cmd string) error {
+	defer c.reset()
+
+	if strings.HasPrefix(cmd, " ") || cmd == c.Last() {
+		return nil
+	}
+
 	if c.cmds.Len() == 0 {
 		c.cmds = &ring.Ring{Value: cmd}
-		return
+		return c.append(cmd)
 	}
 
-	c.reset()
 	if c.cmds.Len() >= c.max {
 		// remove the oldest cmd in the buffer
 		c.cmds = c.cmds.Move(c.cmds.Len() - 1)
 		c.cmds.Unlink(1)
 		c.cmds = c.cmds.Next()
+
+		c.cmds = c.cmds.Move(-1)
+		c.cmds = c.cmds.Link(&ring.Ring{Value: cmd})
+		return c.save()
 	}
 
 	c.cmds = c.cmds.Move(-1)
 	c.cmds = c.cmds.Link(&ring.Ring{Value: cmd})
+	return c.append(cmd)
 }
 
-func (c *CmdHistory) reset() {
+// Last returns the most recently added cmd, "" if there is none
+func (c *CmdHistory) Last() string {
 	if c.cmds.Len() == 0 {
-		return
+		return ""
+	}
+
+	return c.cmds.Prev().Value.(string)
+}
+
+// Expand replaces the history references in input with the cmds they
+// refer to: !! is the last cmd and !n is the nth cmd as numbered by All,
+// from 1. References can be anywhere in input, e.g. cat !! or !3 | wc,
+// except in quotes or after a \. Any other ! is kept as is.
+func (c *CmdHistory) Expand(input string) (string, error) {
+	var expanded strings.Builder
+	runes := []rune(input)
+
+	var quote rune
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else if r == '\\' && quote == '"' && i+1 < len(runes) {
+				expanded.WriteRune(r)
+				i++
+				r = runes[i]
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '\\' && i+1 < len(runes):
+			expanded.WriteRune(r)
+			i++
+			r = runes[i]
+		case r == '!':
+			cmd, end, err := c.reference(runes, i)
+			if err != nil {
+				return "", err
+			}
+
+			if end > i {
+				expanded.WriteString(cmd)
+				i = end - 1
+				continue
+			}
+		}
+
+		expanded.WriteRune(r)
+	}
+
+	return expanded.String(), nil
+}
+
+// reference returns the cmd referred to by the history reference at
+// runes[start] and the position following it, which is start when
+// there is no reference, see Expand
+func (c *CmdHistory) reference(runes []rune, start int) (string, int, error) {
+	end := start + 1
+	if end < len(runes) && runes[end] == '!' {
+		if last := c.Last(); last != "" {
+			return last, end + 1, nil
+		}
+		return "", 0, fmt.Errorf("shell: !!: event not found")
+	}
+
+	for end < len(runes) && runes[end] >= '0' && runes[end] <= '9' {
+		end++
 	}
-	c.cmds = c.cmds.Move(-c.offset)
+
+	if end == start+1 {
+		return "", start, nil
+	}
+
+	ref := string(runes[start:end])
+	n, err := strconv.Atoi(ref[1:])
+	cmds := c.All()
+	if err != nil || n < 1 || n > len(cmds) {
+		return "", 0, fmt.Errorf("shell: %s: event not found", ref)
+	}
+
+	return cmds[n-1], end, nil
+}
+
+// append adds cmd to the end of the history file
+func (c *CmdHistory) append(cmd string) error {
+	if c.file == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(c.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(cmd + "\n")
+	return err
+}
+
+// save rewrites the history file with the current cmds
+func (c *CmdHistory) save() error {
+	if c.file == "" {
+		return nil
+	}
+
+	var b strings.Builder
+	for _, cmd := range c.All() {
+		b.WriteString(cmd + "\n")
+	}
+
+	return ioutil.WriteFile(c.file, []byte(b.String()), 0600)
+}
+
+// countLines returns the number of lines in file, 0 if it can't be read
+func countLines(file string) int {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0
+	}
+
+	return strings.Count(string(b), "\n")
+}
+
+// reset stops browsing
+func (c *CmdHistory) reset() {
+	c.offset = c.cmds.Len()
 }
@@ -1,6 +1,9 @@
 package shell
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -8,12 +11,13 @@ import (
 
 func TestHistoryEmpty(t *testing.T) {
 	h := InitCmdHistory(5)
-	assert.Empty(t, h.Next())
-	assert.Empty(t, h.Prev())
+	assertNext(t, h, "", false)
+	assertPrev(t, h, "", false)
 	assert.Empty(t, h.All())
+	assert.False(t, h.Browsing())
 }
 
-func TestHistoryCycle(t *testing.T) {
+func TestHistoryNavigation(t *testing.T) {
 	h := InitCmdHistory(5)
 
 	// add some cmds
@@ -23,19 +27,28 @@ func TestHistoryCycle(t *testing.T) {
 	}
 	assert.Len(t, h.All(), len(cmds))
 
-	// check cycling backwards
+	// nothing newer than the line being edited
+	assertNext(t, h, "", false)
+
+	// check moving backwards
 	for i := len(cmds) - 1; i >= 0; i-- {
-		assert.Equal(t, cmds[i], h.Prev())
+		assertPrev(t, h, cmds[i], true)
+		assert.True(t, h.Browsing())
 	}
-	// should go back to the end of the array
-	assert.Equal(t, cmds[len(cmds)-1], h.Prev())
-
-	// check cycling forwards
-	// note: h.All() resets the history offset
-	t.Logf("History: %+v", h.All())
-	for i := 0; i < len(cmds)*2; i++ {
-		assert.Equal(t, cmds[i%len(cmds)], h.Next())
+	// should stop at the oldest cmd
+	assertPrev(t, h, cmds[0], false)
+	assertPrev(t, h, cmds[0], false)
+
+	// check moving forwards
+	for i := 1; i < len(cmds); i++ {
+		assertNext(t, h, cmds[i], true)
 	}
+	// back to the line being edited
+	assertNext(t, h, "", true)
+	assert.False(t, h.Browsing())
+	assertNext(t, h, "", false)
+
+	assertPrev(t, h, cmds[len(cmds)-1], true)
 }
 
 func TestHistoryAdd(t *testing.T) {
@@ -43,19 +56,20 @@ func TestHistoryAdd(t *testing.T) {
 	h.Add("ls")
 	cmds := []string{"ls"}
 
-	// Add()ing anywhere in the cycle shouldn't affect where the cmd is added
-	// since Add() resets the history offset
-	h.Next()
+	// Add()ing anywhere while browsing shouldn't affect where the cmd
+	// is added since Add() stops browsing
+	h.Prev()
 	h.Add("cd bueno")
 	cmds = append(cmds, "cd bueno")
+	assert.False(t, h.Browsing())
 
-	h.Next()
-	h.Next()
+	h.Prev()
+	h.Prev()
 	h.Add("cd utils")
 	cmds = append(cmds, "cd utils")
 
 	for i := len(cmds) - 1; i >= 0; i-- {
-		assert.Equal(t, cmds[i], h.Prev())
+		assertPrev(t, h, cmds[i], true)
 	}
 }
 
@@ -73,10 +87,136 @@ func TestHistoryOverflow(t *testing.T) {
 	assert.Len(t, h.All(), h.max)
 
 	// check that the first cmd was bumped out
-	assert.Equal(t, "cd etc", h.Prev())
+	assertPrev(t, h, "cd etc", true)
 	for i := len(cmds) - 1; i >= 1; i-- {
-		assert.Equal(t, cmds[i], h.Prev())
+		assertPrev(t, h, cmds[i], true)
+	}
+	// stops at the oldest
+	assertPrev(t, h, cmds[1], false)
+}
+
+func TestHistorySearch(t *testing.T) {
+	h := InitCmdHistory(5)
+	for _, cmd := range []string{"cd users", "ls", "cat users/bueno", "pwd"} {
+		h.Add(cmd)
 	}
-	// back the beginning
-	assert.Equal(t, "cd etc", h.Prev())
+
+	assert.Equal(t, 2, h.Search("users", 4))
+	assert.Equal(t, 0, h.Search("users", 2))
+	assert.Equal(t, -1, h.Search("users", 0))
+	assert.Equal(t, 3, h.Search("", 10))
+	assert.Equal(t, -1, h.Search("rm", 4))
+}
+
+func assertNext(t *testing.T, h *CmdHistory, cmd string, moved bool) {
+	t.Helper()
+
+	next, ok := h.Next()
+	assert.Equal(t, cmd, next)
+	assert.Equal(t, moved, ok)
+}
+
+func assertPrev(t *testing.T, h *CmdHistory, cmd string, moved bool) {
+	t.Helper()
+
+	prev, ok := h.Prev()
+	assert.Equal(t, cmd, prev)
+	assert.Equal(t, moved, ok)
+}
+
+func TestHistorySkip(t *testing.T) {
+	h := InitCmdHistory(5)
+	h.Add("ls")
+	h.Add("ls")
+	h.Add(" set secret 1")
+	h.Add("pwd")
+	h.Add("ls")
+
+	assert.Equal(t, []string{"ls", "pwd", "ls"}, h.All())
+	assert.Equal(t, "ls", h.Last())
+}
+
+func TestHistoryExpand(t *testing.T) {
+	h := InitCmdHistory(5)
+
+	_, err := h.Expand("!!")
+	assert.Error(t, err)
+
+	h.Add("ls")
+	h.Add("cd users")
+
+	cmd, err := h.Expand("!!")
+	assert.NoError(t, err)
+	assert.Equal(t, "cd users", cmd)
+
+	cmd, err = h.Expand("!1")
+	assert.NoError(t, err)
+	assert.Equal(t, "ls", cmd)
+
+	_, err = h.Expand("!3")
+	assert.Error(t, err)
+	_, err = h.Expand("!0")
+	assert.Error(t, err)
+
+	// not a history reference
+	cmd, err = h.Expand("!users")
+	assert.NoError(t, err)
+	assert.Equal(t, "!users", cmd)
+	cmd, err = h.Expand("hello!")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello!", cmd)
+
+	// references anywhere in the line
+	cmd, err = h.Expand("set a !1")
+	assert.NoError(t, err)
+	assert.Equal(t, "set a ls", cmd)
+	cmd, err = h.Expand("!2 | wc")
+	assert.NoError(t, err)
+	assert.Equal(t, "cd users | wc", cmd)
+	cmd, err = h.Expand("echo !!; !1")
+	assert.NoError(t, err)
+	assert.Equal(t, "echo cd users; ls", cmd)
+	_, err = h.Expand("cat !9")
+	assert.Error(t, err)
+
+	// but not quoted or escaped
+	cmd, err = h.Expand(`set a "!!" '!1' \!! {"b": "!2"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, `set a "!!" '!1' \!! {"b": "!2"}`, cmd)
+}
+
+func TestHistoryFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "history")
+
+	h, err := LoadCmdHistory(3, file)
+	assert.NoError(t, err)
+	assert.Empty(t, h.All())
+
+	for _, cmd := range []string{"ls", "pwd", " rm secret", "cd users"} {
+		assert.NoError(t, h.Add(cmd))
+	}
+
+	h, err = LoadCmdHistory(3, file)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ls", "pwd", "cd users"}, h.All())
+
+	// the oldest cmd is bumped from the file too
+	assert.NoError(t, h.Add("cat users"))
+	b, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "pwd\ncd users\ncat users\n", string(b))
+
+	// loading a smaller history trims the file
+	h, err = LoadCmdHistory(2, file)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cd users", "cat users"}, h.All())
+	b, err = ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "cd users\ncat users\n", string(b))
 }
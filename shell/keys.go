@@ -0,0 +1,148 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode/utf8"
+
+	"github.com/pkg/term"
+)
+
+const esc = 27
+
+var (
+	// enable and disable bracketed paste mode, in which the terminal
+	// wraps pasted text in pasteStart and pasteEnd
+	bracketedPasteOn  = []byte("\x1b[?2004h")
+	bracketedPasteOff = []byte("\x1b[?2004l")
+
+	pasteStart = []byte("\x1b[200~")
+	pasteEnd   = []byte("\x1b[201~")
+)
+
+// key is a single key read from the terminal: a character, a control
+// character or an escape sequence. Text pasted in bracketed paste
+// mode is read as a single key with paste set.
+type key struct {
+	bytes []byte
+	paste bool
+}
+
+// keyReader splits the terminal's input stream into keys
+type keyReader struct {
+	r *bufio.Reader
+}
+
+func newKeyReader(r io.Reader) *keyReader {
+	return &keyReader{r: bufio.NewReader(r)}
+}
+
+// ReadKey returns the next key, it blocks until one is available
+func (kr *keyReader) ReadKey() (key, error) {
+	b, err := kr.r.ReadByte()
+	if err != nil {
+		return key{}, err
+	}
+
+	switch {
+	case b == esc:
+		return kr.readEscape()
+	case b >= utf8.RuneSelf:
+		return kr.readRune(b)
+	default:
+		return key{bytes: []byte{b}}, nil
+	}
+}
+
+// readEscape reads the rest of an escape sequence. An escape with
+// nothing following it in the same read is the escape key itself.
+func (kr *keyReader) readEscape() (key, error) {
+	if kr.r.Buffered() == 0 {
+		return key{bytes: []byte{esc}}, nil
+	}
+
+	b, err := kr.r.ReadByte()
+	if err != nil {
+		return key{}, err
+	}
+
+	seq := []byte{esc, b}
+
+	switch b {
+	case '[':
+		// CSI: parameter and intermediate bytes up to a final byte
+		for {
+			c, err := kr.r.ReadByte()
+			if err != nil {
+				return key{}, err
+			}
+
+			seq = append(seq, c)
+			if c >= 0x40 && c <= 0x7e {
+				break
+			}
+		}
+
+		if bytes.Equal(seq, pasteStart) {
+			return kr.readPaste()
+		}
+	case 'O':
+		// SS3: a single final byte, sent for some arrow and f-keys
+		c, err := kr.r.ReadByte()
+		if err != nil {
+			return key{}, err
+		}
+
+		seq = append(seq, c)
+	}
+
+	// anything else is alt held down with a key
+	return key{bytes: seq}, nil
+}
+
+// readPaste reads pasted text up to the end of the bracketed paste
+func (kr *keyReader) readPaste() (key, error) {
+	var text []byte
+
+	for !bytes.HasSuffix(text, pasteEnd) {
+		c, err := kr.r.ReadByte()
+		if err != nil {
+			return key{}, err
+		}
+
+		text = append(text, c)
+	}
+
+	return key{bytes: text[:len(text)-len(pasteEnd)], paste: true}, nil
+}
+
+// readRune reads the remaining bytes of the UTF-8 encoded
+// character starting with b. Invalid input is returned as is.
+func (kr *keyReader) readRune(b byte) (key, error) {
+	seq := []byte{b}
+
+	for !utf8.FullRune(seq) {
+		c, err := kr.r.ReadByte()
+		if err != nil {
+			return key{}, err
+		}
+
+		seq = append(seq, c)
+	}
+
+	return key{bytes: seq}, nil
+}
+
+// rawReader reads from the terminal in raw mode, restoring
+// the terminal's mode after each read so output isn't affected
+type rawReader struct {
+	t *term.Term
+}
+
+func (rr rawReader) Read(p []byte) (int, error) {
+	rr.t.SetRaw()
+	defer rr.t.Restore()
+
+	return rr.t.Read(p)
+}
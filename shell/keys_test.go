@@ -0,0 +1,81 @@
+package shell
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readKeys(t *testing.T, input string) []key {
+	kr := newKeyReader(strings.NewReader(input))
+
+	var keys []key
+	for {
+		k, err := kr.ReadKey()
+		if err == io.EOF {
+			return keys
+		}
+		if !assert.NoError(t, err) {
+			return keys
+		}
+
+		keys = append(keys, k)
+	}
+}
+
+func TestKeyReader(t *testing.T) {
+	// keys arriving in a single read are split up
+	keys := readKeys(t, "ls\x1b[A\x1b[3~\x1bOH\x1bb\r")
+
+	expected := []string{"l", "s", "\x1b[A", "\x1b[3~", "\x1bOH", "\x1bb", "\r"}
+	if assert.Len(t, keys, len(expected)) {
+		for i, k := range keys {
+			assert.Equal(t, expected[i], string(k.bytes))
+			assert.False(t, k.paste)
+		}
+	}
+
+	assert.True(t, isArrowUp(keys[2].bytes))
+	assert.True(t, isDeleteKey(keys[3].bytes))
+	assert.True(t, isHome(keys[4].bytes))
+	assert.True(t, isWordLeft(keys[5].bytes))
+	assert.True(t, isEnter(keys[6].bytes))
+}
+
+func TestKeyReaderUnicode(t *testing.T) {
+	keys := readKeys(t, "é日🔥")
+
+	if assert.Len(t, keys, 3) {
+		assert.Equal(t, "é", string(keys[0].bytes))
+		assert.Equal(t, "日", string(keys[1].bytes))
+		assert.Equal(t, "🔥", string(keys[2].bytes))
+	}
+}
+
+func TestKeyReaderLoneEscape(t *testing.T) {
+	keys := readKeys(t, "\x1b")
+
+	if assert.Len(t, keys, 1) {
+		assert.True(t, isEsc(keys[0].bytes))
+	}
+}
+
+func TestKeyReaderPaste(t *testing.T) {
+	long := strings.Repeat("x", 5000)
+	keys := readKeys(t, "set a \x1b[200~{\"b\":\r\n \"\x1b[A"+long+"\"}\x1b[201~\r")
+
+	if assert.Len(t, keys, 8) {
+		paste := keys[6]
+		assert.True(t, paste.paste)
+		assert.Equal(t, "{\"b\":\r\n \"\x1b[A"+long+"\"}", string(paste.bytes))
+		assert.True(t, isEnter(keys[7].bytes))
+	}
+}
+
+func TestPasteText(t *testing.T) {
+	assert.Equal(t, "ls users", pasteText([]byte("ls users\n")))
+	assert.Equal(t, "{  \"a\": 1 }", pasteText([]byte("{\r\n\t\"a\": 1\r\n}\r\n")))
+	assert.Equal(t, "a b", pasteText([]byte("a\rb\x1b\x07")))
+}
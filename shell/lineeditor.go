@@ -0,0 +1,209 @@
+package shell
+
+// lineEditor holds the line being edited and the position
+// of the cursor in it, and implements the editing commands.
+// The line is kept as runes, the cursor is a rune index.
+type lineEditor struct {
+	buffer []rune
+	cursor int
+
+	// killed holds the text removed by the last kill
+	// command, it's inserted back by Yank
+	killed []rune
+}
+
+// String returns the line being edited
+func (l *lineEditor) String() string {
+	return string(l.buffer)
+}
+
+// Reset clears the line and returns what it held
+func (l *lineEditor) Reset() string {
+	line := l.String()
+	l.buffer = l.buffer[:0]
+	l.cursor = 0
+
+	return line
+}
+
+// Set replaces the line, moving the cursor to its end
+func (l *lineEditor) Set(line string) {
+	l.buffer = append(l.buffer[:0], []rune(line)...)
+	l.cursor = len(l.buffer)
+}
+
+// Insert adds the UTF-8 encoded b at the cursor
+func (l *lineEditor) Insert(b []byte) {
+	l.insert([]rune(string(b)))
+}
+
+// Backspace deletes the character before the cursor,
+// along with any combining marks following it
+func (l *lineEditor) Backspace() bool {
+	if l.cursor == 0 {
+		return false
+	}
+
+	l.remove(l.previousChar(), l.cursor)
+	return true
+}
+
+// Delete deletes the character under the cursor,
+// along with any combining marks following it
+func (l *lineEditor) Delete() bool {
+	if l.cursor == len(l.buffer) {
+		return false
+	}
+
+	l.remove(l.cursor, l.nextChar())
+	return true
+}
+
+// Left moves the cursor one character left
+func (l *lineEditor) Left() bool {
+	if l.cursor == 0 {
+		return false
+	}
+
+	l.cursor = l.previousChar()
+	return true
+}
+
+// Right moves the cursor one character right
+func (l *lineEditor) Right() bool {
+	if l.cursor == len(l.buffer) {
+		return false
+	}
+
+	l.cursor = l.nextChar()
+	return true
+}
+
+// Home moves the cursor to the start of the line
+func (l *lineEditor) Home() {
+	l.cursor = 0
+}
+
+// End moves the cursor to the end of the line
+func (l *lineEditor) End() {
+	l.cursor = len(l.buffer)
+}
+
+// WordLeft moves the cursor to the start of the current or
+// previous word. Words are separated by spaces and slashes.
+func (l *lineEditor) WordLeft() {
+	l.cursor = l.wordStart(isWordSeparator)
+}
+
+// WordRight moves the cursor to the end of the current or next word
+func (l *lineEditor) WordRight() {
+	i := l.cursor
+	for i < len(l.buffer) && isWordSeparator(l.buffer[i]) {
+		i++
+	}
+	for i < len(l.buffer) && !isWordSeparator(l.buffer[i]) {
+		i++
+	}
+
+	l.cursor = i
+}
+
+// KillWordBackward removes the space separated word before
+// the cursor, like Ctrl-W in bash
+func (l *lineEditor) KillWordBackward() {
+	start := l.wordStart(func(r rune) bool { return r == ' ' })
+	l.kill(start, l.cursor)
+}
+
+// KillToStart removes everything before the cursor
+func (l *lineEditor) KillToStart() {
+	l.kill(0, l.cursor)
+}
+
+// KillToEnd removes everything from the cursor to the end of the line
+func (l *lineEditor) KillToEnd() {
+	l.kill(l.cursor, len(l.buffer))
+}
+
+// Yank inserts the most recently killed text at the cursor
+func (l *lineEditor) Yank() {
+	l.insert(l.killed)
+}
+
+// BeforeCursor returns the part of the line before the cursor
+func (l *lineEditor) BeforeCursor() string {
+	return string(l.buffer[:l.cursor])
+}
+
+// ReplaceBeforeCursor replaces the last n runes before the cursor with s
+func (l *lineEditor) ReplaceBeforeCursor(n int, s string) {
+	l.remove(l.cursor-n, l.cursor)
+	l.insert([]rune(s))
+}
+
+// CursorWidth returns the display width of the line after the cursor,
+// i.e. how many columns the terminal cursor is from the end of the line
+func (l *lineEditor) CursorWidth() int {
+	return runesWidth(l.buffer[l.cursor:])
+}
+
+func (l *lineEditor) insert(runes []rune) {
+	tail := append([]rune{}, l.buffer[l.cursor:]...)
+	l.buffer = append(append(l.buffer[:l.cursor], runes...), tail...)
+	l.cursor += len(runes)
+}
+
+// previousChar returns the start of the character before the cursor.
+// Characters are grapheme clusters, e.g. a letter and its combining
+// marks or emoji joined into one, so the cursor never lands inside one.
+// Clusters can only be found from the start of the line.
+func (l *lineEditor) previousChar() int {
+	start := 0
+	for i := 0; i < l.cursor; i = clusterEnd(l.buffer, i) {
+		start = i
+	}
+
+	return start
+}
+
+// nextChar returns the end of the character under the cursor,
+// see previousChar
+func (l *lineEditor) nextChar() int {
+	return clusterEnd(l.buffer, l.cursor)
+}
+
+// wordStart returns the start of the word before
+// the cursor, skipping separators first
+func (l *lineEditor) wordStart(isSeparator func(rune) bool) int {
+	i := l.cursor
+	for i > 0 && isSeparator(l.buffer[i-1]) {
+		i--
+	}
+	for i > 0 && !isSeparator(l.buffer[i-1]) {
+		i--
+	}
+
+	return i
+}
+
+func (l *lineEditor) kill(start int, end int) {
+	if start == end {
+		return
+	}
+
+	l.killed = append([]rune{}, l.buffer[start:end]...)
+	l.remove(start, end)
+}
+
+func (l *lineEditor) remove(start int, end int) {
+	l.buffer = append(l.buffer[:start], l.buffer[end:]...)
+	if l.cursor > end {
+		l.cursor -= end - start
+	} else if l.cursor > start {
+		l.cursor = start
+	}
+}
+
+func isWordSeparator(r rune) bool {
+	return r == ' ' || r == '/'
+}
@@ -0,0 +1,151 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineEditorInsertAndDelete(t *testing.T) {
+	l := &lineEditor{}
+	l.Insert([]byte("cd users"))
+	assert.Equal(t, 8, l.cursor)
+
+	// fix a typo in the middle of the line
+	l.Home()
+	l.Right()
+	l.Right()
+	l.Insert([]byte(" ~/"))
+	assert.Equal(t, "cd ~/ users", l.String())
+	assert.Equal(t, 5, l.cursor)
+
+	l.Delete()
+	assert.Equal(t, "cd ~/users", l.String())
+
+	l.Backspace()
+	l.Backspace()
+	assert.Equal(t, "cd users", l.String())
+	assert.Equal(t, 3, l.cursor)
+
+	l.End()
+	assert.False(t, l.Delete())
+	assert.False(t, l.Right())
+	assert.True(t, l.Backspace())
+	assert.Equal(t, "cd user", l.String())
+
+	l.Home()
+	assert.False(t, l.Backspace())
+	assert.False(t, l.Left())
+
+	assert.Equal(t, "cd user", l.Reset())
+	assert.Equal(t, "", l.String())
+	assert.Equal(t, 0, l.cursor)
+}
+
+func TestLineEditorWords(t *testing.T) {
+	l := &lineEditor{}
+	l.Set("ls users/bueno/dev")
+
+	l.WordLeft()
+	assert.Equal(t, "ls users/bueno/", l.BeforeCursor())
+	l.WordLeft()
+	assert.Equal(t, "ls users/", l.BeforeCursor())
+	l.WordLeft()
+	l.WordLeft()
+	assert.Equal(t, "", l.BeforeCursor())
+	l.WordLeft()
+	assert.Equal(t, 0, l.cursor)
+
+	l.WordRight()
+	assert.Equal(t, "ls", l.BeforeCursor())
+	l.WordRight()
+	assert.Equal(t, "ls users", l.BeforeCursor())
+
+	l.End()
+	l.KillWordBackward()
+	assert.Equal(t, "ls ", l.String())
+
+	l.Yank()
+	assert.Equal(t, "ls users/bueno/dev", l.String())
+}
+
+func TestLineEditorKill(t *testing.T) {
+	l := &lineEditor{}
+	l.Set("set users/bueno true")
+
+	l.Home()
+	l.WordRight()
+	l.KillToEnd()
+	assert.Equal(t, "set", l.String())
+
+	l.Yank()
+	l.Yank()
+	assert.Equal(t, "set users/bueno true users/bueno true", l.String())
+
+	l.Set("find users name bueno")
+	l.WordLeft()
+	l.KillToStart()
+	assert.Equal(t, "bueno", l.String())
+	assert.Equal(t, 0, l.cursor)
+
+	l.End()
+	l.Yank()
+	assert.Equal(t, "buenofind users name ", l.String())
+}
+
+func TestLineEditorReplaceBeforeCursor(t *testing.T) {
+	l := &lineEditor{}
+	l.Set("cd us other")
+	l.cursor = 5
+
+	l.ReplaceBeforeCursor(2, "users/")
+	assert.Equal(t, "cd users/ other", l.String())
+	assert.Equal(t, 9, l.cursor)
+}
+
+func TestLineEditorUnicode(t *testing.T) {
+	l := &lineEditor{}
+	l.Insert([]byte("cd josé"))
+	assert.Equal(t, 7, l.cursor)
+
+	assert.True(t, l.Backspace())
+	assert.Equal(t, "cd jos", l.String())
+
+	// e followed by a combining acute accent is a single character
+	l.Insert([]byte("e\u0301/🔥"))
+	assert.Equal(t, 0, l.CursorWidth())
+
+	l.Left()
+	assert.Equal(t, 2, l.CursorWidth())
+	l.Left()
+	assert.Equal(t, 3, l.CursorWidth())
+	l.Left()
+	assert.Equal(t, "cd jos", l.BeforeCursor())
+
+	l.Delete()
+	assert.Equal(t, "cd jos/🔥", l.String())
+
+	l.End()
+	l.Backspace()
+	assert.Equal(t, "cd jos/", l.String())
+
+	l.Set("cat 日本")
+	l.ReplaceBeforeCursor(2, "日本語/")
+	assert.Equal(t, "cat 日本語/", l.String())
+	assert.Equal(t, 0, l.CursorWidth())
+	l.Home()
+	assert.Equal(t, 11, l.CursorWidth())
+
+	// emoji joined by zero width joiners are a single character
+	l.Set("cd 👩\u200d💻/🇫🇷")
+	l.Left()
+	assert.Equal(t, 2, l.CursorWidth())
+	l.Left()
+	l.Left()
+	assert.Equal(t, "cd ", l.BeforeCursor())
+	l.Delete()
+	assert.Equal(t, "cd /🇫🇷", l.String())
+	l.End()
+	l.Backspace()
+	assert.Equal(t, "cd /", l.String())
+}
@@ -0,0 +1,196 @@
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Piped is the output of the previous command in a pipeline,
+// which is the input of the command it's piped to
+type Piped struct {
+	Text string
+
+	// Value is the command's structured output set with SetValue,
+	// nil when it only output text, e.g. an external command
+	Value interface{}
+}
+
+// Stdin returns the output piped to the running command,
+// nil when nothing was piped to it
+func (s *Shell) Stdin() *Piped {
+	return s.piped
+}
+
+// Quoted reports whether the running command's ith argument was
+// quoted, args[0] being the command, e.g. so "123" can be used as
+// a string rather than a number
+func (s *Shell) Quoted(i int) bool {
+	return i >= 0 && i < len(s.quoted) && s.quoted[i]
+}
+
+// SetValue sets the running command's structured output, it's passed
+// on alongside the output text when piped to another command
+func (s *Shell) SetValue(value interface{}) {
+	s.value = value
+}
+
+// pipeline is a parsed line of input: commands separated by |,
+// optionally followed by a > or >> redirection to a local file
+type pipeline struct {
+	stages [][]string
+	// quoted[i][j] is set when stages[i][j] was quoted, see Quoted
+	quoted [][]bool
+
+	redirect string
+	append   bool
+}
+
+// parsePipeline splits input into a pipeline, stages is empty
+// when the input is blank
+func parsePipeline(input string) (*pipeline, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pipeline{}
+	if len(tokens) == 0 {
+		return p, nil
+	}
+
+	var stage []string
+	var quoted []bool
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if !t.operator {
+			stage = append(stage, t.text)
+			quoted = append(quoted, t.quoted)
+			continue
+		}
+
+		if len(stage) == 0 {
+			return nil, fmt.Errorf("shell: syntax error near %s", t.text)
+		}
+		p.stages = append(p.stages, stage)
+		p.quoted = append(p.quoted, quoted)
+		stage, quoted = nil, nil
+
+		if t.text == "|" {
+			continue
+		}
+
+		// a redirection ends the pipeline
+		if i != len(tokens)-2 || tokens[i+1].operator {
+			return nil, fmt.Errorf("shell: %s: expected a single file name", t.text)
+		}
+
+		p.redirect = tokens[i+1].text
+		p.append = t.text == ">>"
+		return p, nil
+	}
+
+	if len(stage) == 0 {
+		return nil, fmt.Errorf("shell: syntax error near |")
+	}
+	p.stages = append(p.stages, stage)
+	p.quoted = append(p.quoted, quoted)
+
+	return p, nil
+}
+
+// run runs the pipeline's stages in order. Commands registered with the
+// shell get the previous stage's output from Stdin, any other command is
+// run with os/exec and the previous stage's text piped to its stdin.
+// The last stage's output is written to stdout or the redirection's file.
+// The output of the last fli command is returned.
+func (s *Shell) run(p *pipeline) (string, error) {
+	var out io.Writer = os.Stdout
+
+	if p.redirect != "" {
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if p.append {
+			flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+
+		f, err := os.OpenFile(p.redirect, flags, 0644)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+
+		out = f
+	}
+
+	var piped *Piped
+	var output string
+
+	for i, args := range p.stages {
+		last := i == len(p.stages)-1
+
+		command, err := s.FindCommand(args[0])
+		if err != nil && i == 0 {
+			return "", err
+		}
+
+		if command == nil {
+			text, err := s.exec(args, piped, out, last)
+			if err != nil {
+				return "", err
+			}
+
+			piped = &Piped{Text: text}
+			continue
+		}
+
+		s.piped = piped
+		if i < len(p.quoted) {
+			s.quoted = p.quoted[i]
+		}
+		s.value = nil
+		output, err = command.Handler(args, s)
+		s.piped, s.quoted = nil, nil
+
+		if last && output != "" {
+			fmt.Fprintln(out, output)
+		}
+		if err != nil {
+			return output, err
+		}
+
+		piped = &Piped{Text: output, Value: s.value}
+	}
+
+	return output, nil
+}
+
+// exec runs an external command with piped's text as its input. The
+// last stage's output is streamed to out, otherwise it's returned.
+func (s *Shell) exec(args []string, piped *Piped, out io.Writer, last bool) (string, error) {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+
+	if piped != nil {
+		text := piped.Text
+		if text != "" && !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		cmd.Stdin = strings.NewReader(text)
+	}
+
+	var buffer bytes.Buffer
+	if last {
+		cmd.Stdout = out
+	} else {
+		cmd.Stdout = &buffer
+	}
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("shell: %s: %s", args[0], err)
+	}
+
+	return strings.TrimSuffix(buffer.String(), "\n"), nil
+}
@@ -0,0 +1,145 @@
+package shell
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePipeline(t *testing.T) {
+	p, err := parsePipeline("  ")
+	assert.NoError(t, err)
+	assert.Empty(t, p.stages)
+
+	p, err = parsePipeline(`ls users|grep "a | b" | wc -l >> 'out file'`)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"ls", "users"}, {"grep", "a | b"}, {"wc", "-l"}}, p.stages)
+	assert.Equal(t, "out file", p.redirect)
+	assert.True(t, p.append)
+
+	p, err = parsePipeline(`cat users>users.json`)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"cat", "users"}}, p.stages)
+	assert.Equal(t, "users.json", p.redirect)
+	assert.False(t, p.append)
+
+	p, err = parsePipeline(`set users {"a": "b|c>d"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"set", "users", `{"a": "b|c>d"}`}}, p.stages)
+	assert.Equal(t, [][]bool{{false, false, false}}, p.quoted)
+
+	p, err = parsePipeline(`set code "123" | grep 'a'b`)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]bool{{false, false, true}, {false, true}}, p.quoted)
+
+	for _, input := range []string{
+		"| ls",
+		"ls |",
+		"ls || wc",
+		"ls >",
+		"ls > a b",
+		"ls > a | wc",
+		"> a",
+	} {
+		_, err := parsePipeline(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestRunPipeline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pipeline")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out")
+
+	s := &Shell{}
+	s.AddCommand("ls", func(args []string, s *Shell) (string, error) {
+		s.SetValue([]string{"admin", "bueno"})
+		return "admin\nbueno", nil
+	})
+	s.AddCommand("count", func(args []string, s *Shell) (string, error) {
+		assert.Nil(t, s.Stdin().Value)
+		return strings.Repeat("x", len(strings.Split(s.Stdin().Text, "\n"))), nil
+	})
+	s.AddCommand("first", func(args []string, s *Shell) (string, error) {
+		if s.Stdin() == nil {
+			return "", nil
+		}
+		return s.Stdin().Value.([]string)[0], nil
+	})
+
+	// fli commands pass their structured output on
+	p, _ := parsePipeline("ls | first > " + out)
+	text, err := s.run(p)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", text)
+	assertFile(t, out, "admin\n")
+
+	p, _ = parsePipeline("first >> " + out)
+	_, err = s.run(p)
+	assert.NoError(t, err)
+	assertFile(t, out, "admin\n")
+
+	p, _ = parsePipeline("ls >> " + out)
+	_, err = s.run(p)
+	assert.NoError(t, err)
+	assertFile(t, out, "admin\nadmin\nbueno\n")
+
+	// quoted args are reported to the running command only
+	var quoted []bool
+	s.AddCommand("quoted", func(args []string, s *Shell) (string, error) {
+		for i := range args {
+			quoted = append(quoted, s.Quoted(i))
+		}
+		return "", nil
+	})
+	p, _ = parsePipeline(`quoted 123 "123" {"a": 1}`)
+	_, err = s.run(p)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{false, false, true, false}, quoted)
+	assert.False(t, s.Quoted(2))
+
+	p, _ = parsePipeline("nope | ls")
+	_, err = s.run(p)
+	assert.Error(t, err)
+
+	if _, err := exec.LookPath("tr"); err != nil {
+		t.Skip("tr not found")
+	}
+
+	// external commands get the text
+	p, _ = parsePipeline("ls | tr a-z A-Z | count > " + out)
+	_, err = s.run(p)
+	assert.NoError(t, err)
+	assertFile(t, out, "xx\n")
+
+	p, _ = parsePipeline("ls | tr a-z A-Z > " + out)
+	_, err = s.run(p)
+	assert.NoError(t, err)
+	assertFile(t, out, "ADMIN\nBUENO\n")
+
+	// registered commands take precedence over external ones
+	s.AddCommand("tr", func(args []string, s *Shell) (string, error) {
+		return "registered " + s.Stdin().Text, nil
+	})
+	p, _ = parsePipeline("ls | tr a-z A-Z > " + out)
+	_, err = s.run(p)
+	assert.NoError(t, err)
+	assertFile(t, out, "registered admin\nbueno\n")
+}
+
+func assertFile(t *testing.T, file string, expected string) {
+	t.Helper()
+
+	b, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(b))
+}
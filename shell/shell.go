@@ -1,36 +1,134 @@
 package shell
 
 import (
-	"bytes"
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/pkg/term"
 )
 
+// ErrNotInteractive is returned by ReadLine when the shell isn't
+// reading from the terminal, as there's no one to answer
+var ErrNotInteractive = errors.New("shell: can't read input, not running interactively")
+
 var (
-	backspaceBytes = []byte("\b \b")
-	newLineBytes   = []byte("\n")
+	newLineBytes = []byte("\n")
+	bellBytes    = []byte("\a")
+
+	// clears from the cursor to the end of the screen, lines
+	// wider than the terminal take up several rows
+	clearBelowBytes = []byte("\x1b[J")
 )
 
 // Shell struct for keeping track of shell things
 type Shell struct {
 	term *term.Term
+	keys *keyReader
+
+	// script is read instead of the terminal when the shell
+	// runs non-interactively, see Options.Script
+	script *bufio.Scanner
 
-	// buffer to hold input
-	buffer *bytes.Buffer
+	// line being edited
+	line *lineEditor
+	// linePrompt is the prompt displayed in front of the line
+	linePrompt string
+	// cursorRow and endRow are the rows of the cursor and of the end
+	// of the line as last drawn, counted from the prompt's row
+	cursorRow, endRow int
 
 	commands Commands
 	history  *CmdHistory
+	// draft is the line being edited before browsing the
+	// history, it's restored when browsing past the newest cmd
+	draft string
+
+	// completer completes command arguments
+	completer Completer
+	// lastWasTab is set when the previous key was tab,
+	// a second tab lists the candidates
+	lastWasTab bool
 
 	prompt string
 	input  string
 	err    error
+	// exited is set once exit ran non-interactively, see Next
+	exited bool
+
+	// piped is the input of the running command in a pipeline
+	// and value its structured output, see Stdin and SetValue
+	piped *Piped
+	value interface{}
+	// quoted is set for the running command's quoted args, see Quoted
+	quoted []bool
+}
+
+// Options configures a shell, the zero value is a shell
+// with an in-memory history of DefaultHistorySize cmds
+type Options struct {
+	// HistoryFile persists the history across sessions
+	HistoryFile string
+	// HistorySize is the number of cmds kept in the history
+	HistorySize int
+
+	// Script runs the commands read from it, one per line, instead of
+	// reading them from the terminal, which isn't needed. Blank lines
+	// and lines starting with # are skipped. No prompt is printed and
+	// errors are printed to stderr.
+	Script io.Reader
+}
+
+// New creates a shell that doesn't read any input, its commands
+// are run with Run, e.g. as command line subcommands
+func New(prompt string) *Shell {
+	s := &Shell{
+		line:    &lineEditor{},
+		history: InitCmdHistory(DefaultHistorySize),
+		prompt:  prompt,
+	}
+	s.addBuiltins()
+
+	return s
 }
 
 // Init creates a shell-like env
 func Init(prompt string) (*Shell, error) {
+	return InitWithOptions(prompt, Options{})
+}
+
+// InitWithOptions creates a shell-like env configured by options
+func InitWithOptions(prompt string, options Options) (*Shell, error) {
+	size := options.HistorySize
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+
+	if options.Script != nil {
+		script := bufio.NewScanner(options.Script)
+		script.Buffer(nil, 64*1024*1024)
+
+		s := New(prompt)
+		s.script = script
+		s.history = InitCmdHistory(size)
+
+		return s, nil
+	}
+
+	history := InitCmdHistory(size)
+	if options.HistoryFile != "" {
+		var err error
+		history, err = LoadCmdHistory(size, options.HistoryFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	t, err := term.Open("/dev/tty")
 	if err != nil {
 		return nil, err
@@ -38,14 +136,16 @@ func Init(prompt string) (*Shell, error) {
 
 	s := &Shell{
 		term:    t,
-		buffer:  bytes.NewBuffer([]byte{}),
-		history: InitCmdHistory(50),
+		keys:    newKeyReader(rawReader{t}),
+		line:    &lineEditor{},
+		history: history,
 	}
 
 	s.prompt = prompt
+	s.term.Write(bracketedPasteOn)
 	fmt.Print(s.prompt)
 
-	s.AddCommand("exit", exitHandler)
+	s.addBuiltins()
 
 	return s, nil
 }
@@ -53,6 +153,12 @@ func Init(prompt string) (*Shell, error) {
 // Getters
 // ----------------------------------------------------------------------------
 
+// Interactive returns true when the shell reads from the terminal,
+// false when it runs a script or was created with New
+func (s *Shell) Interactive() bool {
+	return s.term != nil
+}
+
 // Input returns any available input
 func (s *Shell) Input() string {
 	return s.input
@@ -62,6 +168,11 @@ func (s *Shell) Error() error {
 	return s.err
 }
 
+// History returns the shell's command history
+func (s *Shell) History() *CmdHistory {
+	return s.history
+}
+
 func (s *Shell) Prompt() string {
 	return s.prompt
 }
@@ -70,138 +181,561 @@ func (s *Shell) SetPrompt(prompt string) {
 	s.prompt = prompt
 }
 
+// SetCompleter sets the completer used to tab complete the
+// arguments of commands without their own Completer
+func (s *Shell) SetCompleter(completer Completer) {
+	s.completer = completer
+}
+
 // Next returns true if the enter key has been pressed
 func (s *Shell) Next() bool {
+	if !s.Interactive() {
+		return s.script != nil && !s.exited && s.nextScriptLine()
+	}
+
+	s.linePrompt = s.prompt
+
+	// pending is a key left over from a reverse search
+	var pending []byte
+
 	for {
-		c, err := s.getchar()
-		if err != nil {
-			s.err = err
-			return false
+		c := pending
+		pending = nil
+
+		if c == nil {
+			k, err := s.keys.ReadKey()
+			if err != nil {
+				s.err = err
+				return false
+			}
+
+			if k.paste {
+				s.paste(k.bytes)
+				continue
+			}
+
+			c = k.bytes
 		}
 
+		wasTab := s.lastWasTab
+		s.lastWasTab = isTab(c)
+
 		switch {
 		case isEnter(c):
-			s.input = s.flushBuffer()
-			if nonEmpty(s.input) {
-				s.history.Add(s.input)
+			s.newLine()
+			s.history.reset()
+
+			line := s.line.Reset()
+			input, err := s.history.Expand(line)
+			if err != nil {
+				fmt.Println(err)
+				fmt.Print(s.linePrompt)
+				continue
+			}
+
+			// show the cmd a history reference was expanded to
+			if input != line {
+				fmt.Println(input)
 			}
 
-			s.term.Write(newLineBytes)
+			s.input = input
+			if nonEmpty(s.input) {
+				if err := s.history.Add(s.input); err != nil {
+					fmt.Printf("shell: history: %s\n", err)
+				}
+			}
 
 			return true
-		case isDelete(c):
-			if s.buffer.Len() > 0 {
-				s.buffer.Truncate(s.buffer.Len() - 1)
-				s.term.Write(backspaceBytes)
-			}
 		case isArrowUp(c):
-			// Need to stop when we reach position 0
-			previousInput := s.history.Prev()
-			s.overwriteBufferOnScreen(previousInput)
+			if !s.history.Browsing() {
+				s.draft = s.line.String()
+			}
+
+			previousInput, ok := s.history.Prev()
+			if !ok {
+				s.term.Write(bellBytes)
+				continue
+			}
+
+			s.line.Set(previousInput)
+			s.redraw()
 		case isArrowDown(c):
-			// Need to stop when we reach position last position
-			nextInput := s.history.Next()
-			s.overwriteBufferOnScreen(nextInput)
-		case isArrowLeft(c):
-		case isArrowRight(c):
-		case isTab(c):
-			// searchTerm := s.getLastWordFromBuffer()
+			nextInput, ok := s.history.Next()
+			if !ok {
+				s.term.Write(bellBytes)
+				continue
+			}
 
-			// fakeDatasource := []string{"hello", "users"}
-			// tabCompletion, err := FindNextTerm(fakeDatasource, searchTerm)
-			// if err == nil {
-			// 	s.overwriteLastWordOnScreen(tabCompletion)
-			// }
+			if !s.history.Browsing() {
+				nextInput = s.draft
+			}
+
+			s.line.Set(nextInput)
+			s.redraw()
+		case isCtrlR(c):
+			var err error
+			pending, err = s.reverseSearch()
+			if err != nil {
+				s.err = err
+				return false
+			}
+		case isTab(c):
+			s.complete(wasTab)
 		case isCtrlC(c):
 			fmt.Print("Closing app")
 			s.Cleanup()
 			os.Exit(0)
 		default:
-			s.buffer.Write(c)
-			s.term.Write(c)
+			s.edit(c)
 		}
 	}
 }
 
-func (s *Shell) getLastWordFromBuffer() string {
-	bufferString := s.buffer.String()
-	if bufferString == "" {
-		return ""
+// reverseSearch searches the history for the newest cmd containing the
+// query as it's typed, Ctrl-R moves on to older matches. Any other key
+// ends the search with the match in the line and is returned so the
+// caller handles it, e.g. enter runs the match. Ctrl-G, Ctrl-C and escape
+// cancel the search, restoring the line, and return nil.
+func (s *Shell) reverseSearch() ([]byte, error) {
+	original := s.line.String()
+
+	var query []rune
+	match := -1
+	failed := false
+
+	search := func(before int) {
+		i := s.history.Search(string(query), before)
+		failed = i < 0
+		if !failed {
+			match = i
+		}
 	}
 
-	components := strings.Split(bufferString, " ")
-	return components[len(components)-1]
+	all := s.history.All()
+	s.drawSearch(string(query), "", false)
+
+	for {
+		k, err := s.keys.ReadKey()
+		if err != nil {
+			return nil, err
+		}
+
+		c := k.bytes
+		switch {
+		case k.paste:
+			query = append(query, []rune(pasteText(c))...)
+			search(match + 1)
+		case isCtrlR(c):
+			if match >= 0 {
+				search(match)
+			} else {
+				search(len(all))
+			}
+		case isDelete(c), isCtrlH(c):
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+			}
+			match = -1
+			search(len(all))
+		case isCtrlG(c), isCtrlC(c), isEsc(c):
+			s.line.Set(original)
+			s.redraw()
+			return nil, nil
+		case isControl(c):
+			if match >= 0 {
+				s.line.Set(all[match])
+			}
+			s.redraw()
+			return c, nil
+		default:
+			query = append(query, []rune(string(c))...)
+			if match < 0 {
+				search(len(all))
+			} else {
+				search(match + 1)
+			}
+		}
+
+		if len(query) == 0 {
+			match = -1
+		}
+
+		text := ""
+		if match >= 0 {
+			text = all[match]
+		}
+
+		s.drawSearch(string(query), text, failed)
+	}
 }
 
-func (s *Shell) getchar() ([]byte, error) {
-	s.term.SetRaw()
+// drawSearch shows the reverse search prompt and the cmd matching
+// query, placing the cursor at the start of the query in the match
+func (s *Shell) drawSearch(query string, match string, failed bool) {
+	prompt := "(reverse-i-search)`"
+	if failed {
+		prompt = "(failed reverse-i-search)`"
+	}
+	prompt += query + "': "
 
-	// not sure if this should be hardcoded as 256 chars
-	// This limits pasting into the buffer to 256 chars
-	bytes := make([]byte, 256)
-	numRead, err := s.term.Read(bytes)
-	if err != nil {
-		return nil, err
+	cursor := runesWidth([]rune(match))
+	if i := strings.Index(match, query); i >= 0 {
+		cursor = runesWidth([]rune(match[:i]))
 	}
 
-	s.term.Restore()
+	s.draw(prompt, match, cursor)
+}
+
+// nextScriptLine reads the script's next command, see Options.Script
+func (s *Shell) nextScriptLine() bool {
+	for s.script.Scan() {
+		line := strings.TrimSpace(s.script.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		s.input = line
+		return true
+	}
 
-	return bytes[0:numRead], nil
+	s.err = s.script.Err()
+	return false
 }
 
-func (s *Shell) flushBuffer() string {
-	input := s.buffer.String()
-	s.buffer.Reset()
+// ReadLine prints message and reads a single line of input,
+// e.g. to confirm an action. The line is not added to the history.
+// Ctrl-C cancels and returns an error. Returns ErrNotInteractive
+// when not reading from the terminal.
+func (s *Shell) ReadLine(message string) (string, error) {
+	if !s.Interactive() {
+		return "", ErrNotInteractive
+	}
+
+	s.linePrompt = message
+	fmt.Print(message)
 
-	return input
+	for {
+		k, err := s.keys.ReadKey()
+		if err != nil {
+			return "", err
+		}
+
+		if k.paste {
+			s.paste(k.bytes)
+			continue
+		}
+
+		c := k.bytes
+		switch {
+		case isEnter(c):
+			s.newLine()
+			return s.line.Reset(), nil
+		case isCtrlC(c):
+			s.newLine()
+			s.line.Reset()
+			return "", fmt.Errorf("shell: cancelled")
+		default:
+			s.edit(c)
+		}
+	}
+}
+
+// edit applies an editing key to the line and redraws it,
+// any other printable input is inserted at the cursor
+func (s *Shell) edit(c []byte) {
+	l := s.line
+
+	switch {
+	case isDelete(c), isCtrlH(c):
+		l.Backspace()
+	case isDeleteKey(c), isCtrlD(c):
+		l.Delete()
+	case isArrowLeft(c), isCtrlB(c):
+		l.Left()
+	case isArrowRight(c), isCtrlF(c):
+		l.Right()
+	case isHome(c):
+		l.Home()
+	case isEnd(c):
+		l.End()
+	case isWordLeft(c):
+		l.WordLeft()
+	case isWordRight(c):
+		l.WordRight()
+	case isCtrlW(c):
+		l.KillWordBackward()
+	case isCtrlU(c):
+		l.KillToStart()
+	case isCtrlK(c):
+		l.KillToEnd()
+	case isCtrlY(c):
+		l.Yank()
+	case isControl(c):
+		// ignore unsupported keys and escape sequences
+		return
+	default:
+		l.Insert(c)
+	}
+
+	s.redraw()
 }
 
-func (s *Shell) overwriteBufferOnScreen(buffer string) {
-	bufferBytes := []byte(buffer)
+// paste inserts pasted text at the cursor, see pasteText
+func (s *Shell) paste(text []byte) {
+	s.lastWasTab = false
+	s.line.Insert([]byte(pasteText(text)))
+	s.redraw()
+}
 
-	// delete everything in current buffers
-	length := s.buffer.Len()
-	for i := 0; i < length; i++ {
-		s.term.Write(backspaceBytes)
+// pasteText prepares pasted text for the line. The line can't hold
+// newlines, so pasted lines are joined with spaces rather than run,
+// and other control characters are dropped.
+func pasteText(text []byte) string {
+	t := strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(string(text))
+	t = strings.TrimRight(t, "\n")
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n', r == '\t':
+			return ' '
+		case r < 32, r == 127:
+			return -1
+		}
+		return r
+	}, t)
+}
+
+// redraw rewrites the prompt and line on the current
+// terminal line and moves the cursor into place
+func (s *Shell) redraw() {
+	line := s.line.String()
+	s.draw(s.linePrompt, line, runesWidth([]rune(line))-s.line.CursorWidth())
+}
+
+// draw redraws prompt followed by text over what was last drawn and
+// places the cursor cursor columns into text, see drawLine
+func (s *Shell) draw(prompt string, text string, cursor int) {
+	var b strings.Builder
+	s.cursorRow, s.endRow = drawLine(&b, prompt, text, cursor, terminalColumns(), s.cursorRow)
+	s.term.Write([]byte(b.String()))
+}
+
+// drawLine writes the escape sequences drawing prompt and text from
+// the start of the row the prompt was last drawn on, cursorRow rows
+// up, to b. Text wider than the terminal wraps onto the next rows,
+// which are cleared along with whatever was drawn below them.
+// The cursor is placed cursor columns into text, its row and the row
+// the text ends on are returned, counted from the prompt's row.
+func drawLine(b *strings.Builder, prompt string, text string, cursor int, columns int, cursorRow int) (int, int) {
+	if cursorRow > 0 {
+		fmt.Fprintf(b, "\x1b[%dA", cursorRow)
+	}
+
+	b.WriteString("\r")
+	b.WriteString(prompt)
+	b.WriteString(text)
+
+	promptWidth := runesWidth([]rune(prompt))
+	end := promptWidth + runesWidth([]rune(text))
+	if end > 0 && end%columns == 0 {
+		// the terminal only wraps once the next character is written,
+		// move to the next row so the cursor is placed from there
+		b.WriteString("\r\n")
+	}
+	b.Write(clearBelowBytes)
+
+	position := promptWidth + cursor
+	row, endRow := position/columns, end/columns
+
+	if up := endRow - row; up > 0 {
+		fmt.Fprintf(b, "\x1b[%dA", up)
 	}
 
-	s.flushBuffer()
+	b.WriteString("\r")
+	if column := position % columns; column > 0 {
+		fmt.Fprintf(b, "\x1b[%dC", column)
+	}
+
+	return row, endRow
+}
+
+// newLine moves the cursor past the end of the line as last drawn
+// and onto a new row, the next line is drawn from there
+func (s *Shell) newLine() {
+	if down := s.endRow - s.cursorRow; down > 0 {
+		fmt.Fprintf(s.term, "\x1b[%dB", down)
+	}
 
-	s.buffer.Write(bufferBytes)
-	s.term.Write(bufferBytes)
+	s.term.Write([]byte("\r\n"))
+	s.cursorRow, s.endRow = 0, 0
 }
 
-func (s *Shell) overwriteLastWordOnScreen(word string) {
-	bufferString := s.buffer.String()
-	if bufferString == "" {
-		s.buffer.Write([]byte(word))
-		s.term.Write([]byte(word))
+// complete tab completes the word before the cursor. Command names
+// are completed in first position, arguments with the command's
+// completer, see argumentCompleter. In a pipeline, the last stage's
+// command is completed.
+// A single candidate is completed, otherwise the common prefix of the
+// candidates is completed and a second tab lists them.
+func (s *Shell) complete(listCandidates bool) {
+	args, raw := completionArgs(s.line.BeforeCursor())
+	if args == nil {
+		s.term.Write(bellBytes)
 		return
 	}
+	word := args[len(args)-1]
 
-	components := strings.Split(bufferString, " ")
-	lastWord := components[len(components)-1]
+	var candidates []string
+	if len(args) == 1 {
+		candidates = FindTerms(s.CommandNames(), word)
+	} else if completer := s.argumentCompleter(args[0]); completer != nil {
+		candidates = completer(args)
+	}
+
+	switch len(candidates) {
+	case 0:
+		s.term.Write(bellBytes)
+	case 1:
+		completion := quoteCompletion(candidates[0], raw)
+		if !strings.HasSuffix(candidates[0], "/") {
+			completion = closeQuote(completion) + " "
+		}
+		s.line.ReplaceBeforeCursor(utf8.RuneCountInString(raw), completion)
+		s.redraw()
+	default:
+		prefix := CommonPrefix(candidates)
+		if len(prefix) > len(word) {
+			s.line.ReplaceBeforeCursor(utf8.RuneCountInString(raw), quoteCompletion(prefix, raw))
+			s.redraw()
+			return
+		}
+
+		if !listCandidates {
+			s.term.Write(bellBytes)
+			return
+		}
 
-	// delete last word from buffers
-	for i := 0; i < len(lastWord); i++ {
-		s.term.Write(backspaceBytes)
+		s.newLine()
+		s.term.Write([]byte(strings.Join(candidates, "  ")))
+		s.term.Write(newLineBytes)
+		s.redraw()
 	}
+}
 
-	length := len(bufferString) - len(lastWord)
-	s.buffer.Truncate(length)
+// completionArgs splits the line before the cursor into the words
+// to complete, see SplitWords. The words are those of the pipeline's
+// last stage, the command being typed, and are nil after a redirection.
+// The last word is the one being typed, "" after a space or operator,
+// and raw is how it was typed, quotes included.
+func completionArgs(line string) (args []string, raw string) {
+	tokens := partialTokens(line)
+
+	redirected := false
+	for _, t := range tokens {
+		if t.operator {
+			args = nil
+			redirected = t.text != "|"
+			continue
+		}
 
-	s.buffer.Write([]byte(word))
-	s.term.Write([]byte(word))
+		args = append(args, t.text)
+	}
+
+	if redirected {
+		return nil, ""
+	}
+
+	last := len(tokens) - 1
+	if last < 0 || tokens[last].operator || tokens[last].end < len([]rune(line)) {
+		return append(args, ""), ""
+	}
+
+	return args, string([]rune(line)[tokens[last].start:])
+}
+
+// quoteCompletion opens a double quote before completion when it
+// has whitespace or raw, the word as typed, was quoted. The quote is
+// left open so the word can be completed further.
+func quoteCompletion(completion string, raw string) string {
+	if strings.HasPrefix(raw, "'") || strings.HasPrefix(raw, "\"") || strings.IndexFunc(completion, unicode.IsSpace) >= 0 {
+		return "\"" + strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(completion)
+	}
+
+	return completion
 }
 
+// closeQuote closes the quote opened by quoteCompletion
+func closeQuote(completion string) string {
+	if strings.HasPrefix(completion, "\"") {
+		return completion + "\""
+	}
+
+	return completion
+}
+
+// argumentCompleter returns the completer for the arguments of
+// the named command, falling back to the shell's completer
+func (s *Shell) argumentCompleter(name string) Completer {
+	command, err := s.FindCommand(name)
+	if err == nil && command.Completer != nil {
+		return command.Completer
+	}
+
+	return s.completer
+}
+
+// addBuiltins registers the commands every shell has
+func (s *Shell) addBuiltins() {
+	s.Register(&Command{
+		Name:    "exit",
+		Handler: exitHandler,
+		Help:    "Exit the shell",
+	}, &Command{
+		Name:      "help",
+		Handler:   helpHandler,
+		Usage:     "[command]",
+		Help:      "List the commands, or show a command's usage",
+		Completer: Positional(s.commandCompleter),
+	})
+}
+
+// exitHandler exits the app when interactive, otherwise it stops
+// reading the script and the caller decides how to exit
 func exitHandler(args []string, s *Shell) (string, error) {
+	if !s.Interactive() {
+		s.exited = true
+		return "", nil
+	}
+
 	s.Cleanup()
 	os.Exit(0)
 	return "", nil
 }
 
+// printPrompt prints the prompt when reading from the terminal
+func (s *Shell) printPrompt() {
+	if s.Interactive() {
+		fmt.Print(s.prompt)
+	}
+}
+
+// printError prints err, to stderr when not reading from the
+// terminal so it's kept apart from the commands' output
+func (s *Shell) printError(err error) {
+	if s.Interactive() {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, err)
+}
+
 // Cleanup does any work needed to cleanly close the shell
 func (s *Shell) Cleanup() {
+	if !s.Interactive() {
+		return
+	}
+
+	s.term.Write(bracketedPasteOff)
 	s.term.Restore()
 	s.term.Close()
 }
@@ -0,0 +1,82 @@
+package shell
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompletionArgs(t *testing.T) {
+	tests := []struct {
+		line string
+		args []string
+		raw  string
+	}{
+		{"", []string{""}, ""},
+		{"c", []string{"c"}, "c"},
+		{"cat ", []string{"cat", ""}, ""},
+		{"cat -p us", []string{"cat", "-p", "us"}, "us"},
+		{"cat  users/a  ", []string{"cat", "users/a", ""}, ""},
+		{`cat "users/Jane D`, []string{"cat", "users/Jane D"}, `"users/Jane D`},
+		{`set 'a b' {"x": `, []string{"set", "a b", `{"x": `}, `{"x": `},
+		{"cat users | gr", []string{"gr"}, "gr"},
+		{"cat users |", []string{""}, ""},
+		{"ls | cat -p us", []string{"cat", "-p", "us"}, "us"},
+		{`ls "a | b" us`, []string{"ls", "a | b", "us"}, "us"},
+		{"ls > out", nil, ""},
+		{"ls >> ", nil, ""},
+	}
+
+	for _, test := range tests {
+		args, raw := completionArgs(test.line)
+		assert.Equal(t, test.args, args, test.line)
+		assert.Equal(t, test.raw, raw, test.line)
+	}
+}
+
+func TestQuoteCompletion(t *testing.T) {
+	assert.Equal(t, "users/", quoteCompletion("users/", "us"))
+	assert.Equal(t, `"users/Jane Doe/`, quoteCompletion("users/Jane Doe/", "users/J"))
+	assert.Equal(t, `"users/`, quoteCompletion("users/", `'us`))
+	assert.Equal(t, `"say \"hi\""`, closeQuote(quoteCompletion(`say "hi"`, `"s`)))
+}
+
+func TestDrawLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		cursor    int
+		cursorRow int
+		expected  string
+		row, end  int
+	}{
+		{"fits", "ls", 2, 0, "\r> ls\x1b[J\r\x1b[4C", 0, 0},
+		{"cursor inside", "ls", 0, 0, "\r> ls\x1b[J\r\x1b[2C", 0, 0},
+		{"wraps", "cat users", 9, 0, "\r> cat users\x1b[J\r\x1b[1C", 1, 1},
+		{"cursor on first row", "cat users", 0, 0, "\r> cat users\x1b[J\x1b[1A\r\x1b[2C", 0, 1},
+		{"cursor on second row", "cat users", 9, 1, "\x1b[1A\r> cat users\x1b[J\r\x1b[1C", 1, 1},
+		{"ends on the edge", "cat user", 8, 0, "\r> cat user\r\n\x1b[J\r", 1, 1},
+		{"wide characters", "日本語テ", 2, 0, "\r> 日本語テ\r\n\x1b[J\x1b[1A\r\x1b[4C", 0, 1},
+	}
+
+	for _, test := range tests {
+		var b strings.Builder
+		row, end := drawLine(&b, "> ", test.text, test.cursor, 10, test.cursorRow)
+
+		assert.Equal(t, test.expected, b.String(), test.name)
+		assert.Equal(t, test.row, row, test.name)
+		assert.Equal(t, test.end, end, test.name)
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	null, err := os.Open(os.DevNull)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer null.Close()
+
+	assert.False(t, IsTerminal(null))
+}
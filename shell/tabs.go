@@ -1,6 +1,11 @@
 package shell
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
 
 func FindNextTerm(values []string, term string) (string, error) {
 	low := 0
@@ -58,3 +63,37 @@ func firstNCharactersMatch(n int, a string, b string) int {
 
 	return 0
 }
+
+// FindTerms returns every value starting with term, sorted
+func FindTerms(values []string, term string) []string {
+	var matches []string
+	for _, value := range values {
+		if strings.HasPrefix(value, term) {
+			matches = append(matches, value)
+		}
+	}
+	sort.Strings(matches)
+
+	return matches
+}
+
+// CommonPrefix returns the longest prefix shared by every value
+func CommonPrefix(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	prefix := values[0]
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	// don't split a multibyte character
+	for len(prefix) > 0 && !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+
+	return prefix
+}
@@ -42,3 +42,20 @@ func TestTabCompletionSearch(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Equal(t, result, "")
 }
+
+func TestFindTerms(t *testing.T) {
+	given := []string{"users", "apple", "bueno", "boom", "test"}
+
+	assert.Equal(t, []string{"boom", "bueno"}, shell.FindTerms(given, "b"))
+	assert.Equal(t, []string{"bueno"}, shell.FindTerms(given, "bu"))
+	assert.Equal(t, []string{"apple", "boom", "bueno", "test", "users"}, shell.FindTerms(given, ""))
+	assert.Empty(t, shell.FindTerms(given, "p"))
+}
+
+func TestCommonPrefix(t *testing.T) {
+	assert.Equal(t, "", shell.CommonPrefix(nil))
+	assert.Equal(t, "users", shell.CommonPrefix([]string{"users"}))
+	assert.Equal(t, "users/b", shell.CommonPrefix([]string{"users/bueno", "users/boom"}))
+	assert.Equal(t, "", shell.CommonPrefix([]string{"apple", "boom"}))
+	assert.Equal(t, "caf", shell.CommonPrefix([]string{"café", "cafè"}))
+}
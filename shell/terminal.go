@@ -0,0 +1,39 @@
+package shell
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// defaultColumns is used when the terminal's width can't be read
+const defaultColumns = 80
+
+// winsize is the terminal size read with TIOCGWINSZ
+type winsize struct {
+	rows, columns, xpixels, ypixels uint16
+}
+
+func getWinsize(f *os.File) (winsize, bool) {
+	var size winsize
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&size)))
+	return size, errno == 0
+}
+
+// IsTerminal returns true if f is a terminal rather than a file,
+// pipe or another character device such as /dev/null
+func IsTerminal(f *os.File) bool {
+	_, ok := getWinsize(f)
+	return ok
+}
+
+// terminalColumns returns the width of the terminal in columns
+func terminalColumns() int {
+	for _, f := range []*os.File{os.Stdin, os.Stdout} {
+		if size, ok := getWinsize(f); ok && size.columns > 0 {
+			return int(size.columns)
+		}
+	}
+
+	return defaultColumns
+}
@@ -51,3 +51,79 @@ func isArrowLeft(b []byte) bool {
 func isCtrlC(b []byte) bool {
 	return bytes.Equal(b, []byte{3})
 }
+
+func isDeleteKey(b []byte) bool {
+	return bytes.Equal(b, []byte{27, 91, 51, 126})
+}
+
+func isHome(b []byte) bool {
+	return bytes.Equal(b, []byte{27, 91, 72}) ||
+		bytes.Equal(b, []byte{27, 79, 72}) ||
+		bytes.Equal(b, []byte{27, 91, 49, 126}) ||
+		bytes.Equal(b, []byte{1})
+}
+
+func isEnd(b []byte) bool {
+	return bytes.Equal(b, []byte{27, 91, 70}) ||
+		bytes.Equal(b, []byte{27, 79, 70}) ||
+		bytes.Equal(b, []byte{27, 91, 52, 126}) ||
+		bytes.Equal(b, []byte{5})
+}
+
+func isCtrlB(b []byte) bool {
+	return bytes.Equal(b, []byte{2})
+}
+
+func isCtrlD(b []byte) bool {
+	return bytes.Equal(b, []byte{4})
+}
+
+func isCtrlF(b []byte) bool {
+	return bytes.Equal(b, []byte{6})
+}
+
+func isCtrlH(b []byte) bool {
+	return bytes.Equal(b, []byte{8})
+}
+
+func isCtrlK(b []byte) bool {
+	return bytes.Equal(b, []byte{11})
+}
+
+func isCtrlU(b []byte) bool {
+	return bytes.Equal(b, []byte{21})
+}
+
+func isCtrlW(b []byte) bool {
+	return bytes.Equal(b, []byte{23})
+}
+
+func isCtrlY(b []byte) bool {
+	return bytes.Equal(b, []byte{25})
+}
+
+// Alt-B or Ctrl-Left
+func isWordLeft(b []byte) bool {
+	return bytes.Equal(b, []byte{27, 98}) ||
+		bytes.Equal(b, []byte{27, 91, 49, 59, 53, 68})
+}
+
+// Alt-F or Ctrl-Right
+func isWordRight(b []byte) bool {
+	return bytes.Equal(b, []byte{27, 102}) ||
+		bytes.Equal(b, []byte{27, 91, 49, 59, 53, 67})
+}
+
+// isControl returns true for control characters and escape
+// sequences that aren't handled, so they aren't inserted
+func isControl(b []byte) bool {
+	return len(b) == 0 || b[0] < 32 || b[0] == 127
+}
+
+func isCtrlG(b []byte) bool {
+	return bytes.Equal(b, []byte{7})
+}
+
+func isCtrlR(b []byte) bool {
+	return bytes.Equal(b, []byte{18})
+}
@@ -0,0 +1,169 @@
+package shell
+
+import "unicode"
+
+// wideRanges are the east asian wide and fullwidth ranges,
+// plus emoji, that take up two columns in a terminal
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},   // Hangul Jamo
+	{0x231A, 0x231B},   // watch, hourglass
+	{0x2329, 0x232A},   // angle brackets
+	{0x23E9, 0x23EC},   // media controls
+	{0x23F0, 0x23F0},   // alarm clock
+	{0x23F3, 0x23F3},   // hourglass
+	{0x25FD, 0x25FE},   // small squares
+	{0x2614, 0x2615},   // umbrella, hot beverage
+	{0x2648, 0x2653},   // zodiac
+	{0x267F, 0x267F},   // wheelchair
+	{0x2693, 0x2693},   // anchor
+	{0x26A1, 0x26A1},   // high voltage
+	{0x26AA, 0x26AB},   // circles
+	{0x26BD, 0x26BE},   // soccer, baseball
+	{0x26C4, 0x26C5},   // snowman, sun
+	{0x26CE, 0x26CE},   // ophiuchus
+	{0x26D4, 0x26D4},   // no entry
+	{0x26EA, 0x26EA},   // church
+	{0x26F2, 0x26F3},   // fountain, golf
+	{0x26F5, 0x26F5},   // sailboat
+	{0x26FA, 0x26FA},   // tent
+	{0x26FD, 0x26FD},   // fuel pump
+	{0x2705, 0x2705},   // check mark
+	{0x270A, 0x270B},   // fists
+	{0x2728, 0x2728},   // sparkles
+	{0x274C, 0x274C},   // cross mark
+	{0x274E, 0x274E},   // cross mark
+	{0x2753, 0x2755},   // question marks
+	{0x2757, 0x2757},   // exclamation mark
+	{0x2795, 0x2797},   // math symbols
+	{0x27B0, 0x27B0},   // curly loop
+	{0x27BF, 0x27BF},   // double curly loop
+	{0x2B1B, 0x2B1C},   // large squares
+	{0x2B50, 0x2B50},   // star
+	{0x2B55, 0x2B55},   // circle
+	{0x2E80, 0x303E},   // CJK radicals, punctuation
+	{0x3041, 0x33FF},   // Hiragana, Katakana, CJK symbols
+	{0x3400, 0x4DBF},   // CJK extension A
+	{0x4E00, 0x9FFF},   // CJK unified ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xA960, 0xA97F},   // Hangul Jamo extended A
+	{0xAC00, 0xD7A3},   // Hangul syllables
+	{0xF900, 0xFAFF},   // CJK compatibility ideographs
+	{0xFE10, 0xFE19},   // vertical forms
+	{0xFE30, 0xFE6F},   // CJK compatibility forms
+	{0xFF00, 0xFF60},   // fullwidth forms
+	{0xFFE0, 0xFFE6},   // fullwidth signs
+	{0x16FE0, 0x18AFF}, // Tangut
+	{0x1B000, 0x1B2FF}, // Kana supplement
+	{0x1F004, 0x1F004}, // mahjong tile
+	{0x1F0CF, 0x1F0CF}, // playing card
+	{0x1F18E, 0x1F18E}, // AB button
+	{0x1F191, 0x1F19A}, // squared words
+	{0x1F200, 0x1F2FF}, // enclosed ideographs
+	{0x1F300, 0x1F64F}, // pictographs, emoticons
+	{0x1F680, 0x1F6FF}, // transport and map symbols
+	{0x1F7E0, 0x1F7EB}, // colored circles and squares
+	{0x1F90C, 0x1F9FF}, // supplemental symbols and pictographs
+	{0x1FA70, 0x1FAFF}, // symbols and pictographs extended A
+	{0x20000, 0x3FFFD}, // CJK extensions B and up
+}
+
+// runeWidth returns the number of terminal columns r takes up:
+// 0 for combining marks and other zero width characters,
+// 2 for wide characters and emoji, 1 otherwise
+func runeWidth(r rune) int {
+	switch {
+	case r == 0x200B, r == 0x200C, r == 0x200D, r == 0x2060, r == 0xFEFF:
+		// zero width spaces and joiners
+		return 0
+	case r >= 0xFE00 && r <= 0xFE0F, r >= 0xE0100 && r <= 0xE01EF:
+		// variation selectors
+		return 0
+	case r >= 0x1F3FB && r <= 0x1F3FF:
+		// emoji skin tone modifiers, drawn as part of the emoji
+		return 0
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case r < 0x1100:
+		return 1
+	}
+
+	// binary search for r in wideRanges
+	l, h := 0, len(wideRanges)-1
+	for l <= h {
+		mid := (l + h) / 2
+		switch {
+		case r < wideRanges[mid][0]:
+			h = mid - 1
+		case r > wideRanges[mid][1]:
+			l = mid + 1
+		default:
+			return 2
+		}
+	}
+
+	return 1
+}
+
+// runesWidth returns the number of terminal columns runes take up,
+// measured per character as displayed, see clusterEnd
+func runesWidth(runes []rune) int {
+	width := 0
+	for i := 0; i < len(runes); {
+		end := clusterEnd(runes, i)
+		width += clusterWidth(runes[i:end])
+		i = end
+	}
+
+	return width
+}
+
+const (
+	zeroWidthJoiner = 0x200D
+	emojiVariation  = 0xFE0F
+)
+
+// clusterEnd returns the end of the grapheme cluster, the character as
+// displayed, starting at runes[start]: a rune followed by its combining
+// marks, variation selectors and skin tones, emoji joined by zero width
+// joiners, e.g. 👩‍💻, or a pair of regional indicators, i.e. a flag.
+func clusterEnd(runes []rune, start int) int {
+	i := start + 1
+	if isRegionalIndicator(runes[start]) && i < len(runes) && isRegionalIndicator(runes[i]) {
+		return i + 1
+	}
+
+	for i < len(runes) {
+		switch {
+		case runes[i] == zeroWidthJoiner:
+			// the joiner and the rune it joins
+			i += 2
+		case runeWidth(runes[i]) == 0:
+			i++
+		default:
+			return i
+		}
+	}
+
+	return len(runes)
+}
+
+// clusterWidth returns the number of terminal columns the grapheme
+// cluster takes up, the width of its first rune unless the cluster
+// is displayed as an emoji
+func clusterWidth(cluster []rune) int {
+	if len(cluster) == 2 && isRegionalIndicator(cluster[0]) {
+		return 2
+	}
+
+	for _, r := range cluster[1:] {
+		if r == emojiVariation {
+			return 2
+		}
+	}
+
+	return runeWidth(cluster[0])
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
@@ -0,0 +1,51 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuneWidth(t *testing.T) {
+	assert.Equal(t, 1, runeWidth('a'))
+	assert.Equal(t, 1, runeWidth('é'))
+	assert.Equal(t, 0, runeWidth('\u0301'))
+	assert.Equal(t, 0, runeWidth('\u200d'))
+	assert.Equal(t, 0, runeWidth('\ufe0f'))
+	assert.Equal(t, 2, runeWidth('日'))
+	assert.Equal(t, 2, runeWidth('한'))
+	assert.Equal(t, 2, runeWidth('Ａ'))
+	assert.Equal(t, 2, runeWidth('🔥'))
+	assert.Equal(t, 2, runeWidth('🦊'))
+	assert.Equal(t, 1, runeWidth('→'))
+}
+
+func TestRunesWidth(t *testing.T) {
+	assert.Equal(t, 0, runesWidth(nil))
+	assert.Equal(t, 5, runesWidth([]rune("users")))
+	assert.Equal(t, 4, runesWidth([]rune("jos\u00e9")))
+	assert.Equal(t, 4, runesWidth([]rune("jose\u0301")))
+	assert.Equal(t, 6, runesWidth([]rune("日本語")))
+	assert.Equal(t, 2, runesWidth([]rune("\u2705\ufe0f")))
+
+	// grapheme clusters
+	assert.Equal(t, 2, runesWidth([]rune("\u2764\ufe0f")))
+	assert.Equal(t, 2, runesWidth([]rune("👩\u200d💻")))
+	assert.Equal(t, 2, runesWidth([]rune("👨\u200d👩\u200d👧")))
+	assert.Equal(t, 2, runesWidth([]rune("👍🏽")))
+	assert.Equal(t, 4, runesWidth([]rune("🇫🇷🇯🇵")))
+	assert.Equal(t, 2, runesWidth([]rune("a\u0301\u0302b\u200d")))
+}
+
+func TestClusterEnd(t *testing.T) {
+	runes := []rune("e\u0301👩\u200d💻🇫🇷x")
+
+	var clusters []string
+	for i := 0; i < len(runes); {
+		end := clusterEnd(runes, i)
+		clusters = append(clusters, string(runes[i:end]))
+		i = end
+	}
+
+	assert.Equal(t, []string{"e\u0301", "👩\u200d💻", "🇫🇷", "x"}, clusters)
+}
@@ -0,0 +1,209 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// SplitWords splits input into words like a shell does. Words are
+// separated by whitespace, which can be kept in a word by quoting it:
+//
+//	'single quotes' keep everything as is
+//	"double quotes" allow \" and \\ escapes
+//	\ outside of quotes escapes the next character
+//
+// A word starting with { or [ is read up to its matching bracket and
+// kept as is, so JSON literals are a single word with their quotes.
+// The pipeline operators |, > and >> are split into words of their own
+// unless they're quoted.
+func SplitWords(input string) ([]string, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var words []string
+	for _, t := range tokens {
+		words = append(words, t.text)
+	}
+
+	return words, nil
+}
+
+// token is a word or an unquoted pipeline operator. start and end
+// are the positions of its runes in the input.
+type token struct {
+	text     string
+	operator bool
+	// quoted is set when the word, or part of it, was quoted
+	quoted bool
+
+	start, end int
+}
+
+// tokenize splits input into words and operators, see SplitWords
+func tokenize(input string) ([]token, error) {
+	return scan(input, false)
+}
+
+// partialTokens tokenizes a line that is still being typed, an
+// unterminated quote or JSON literal ends with the input instead
+// of being an error
+func partialTokens(input string) []token {
+	tokens, _ := scan(input, true)
+	return tokens
+}
+
+func scan(input string, partial bool) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		switch {
+		case unicode.IsSpace(runes[i]):
+			i++
+		case runes[i] == '>' && i+1 < len(runes) && runes[i+1] == '>':
+			tokens = append(tokens, token{text: ">>", operator: true, start: i, end: i + 2})
+			i += 2
+		case isOperator(runes[i]):
+			tokens = append(tokens, token{text: string(runes[i]), operator: true, start: i, end: i + 1})
+			i++
+		default:
+			word, quoted, end, err := readWord(runes, i, partial)
+			if err != nil {
+				return nil, err
+			}
+
+			tokens = append(tokens, token{text: word, quoted: quoted, start: i, end: end})
+			i = end
+		}
+	}
+
+	return tokens, nil
+}
+
+func isOperator(r rune) bool {
+	return r == '|' || r == '>'
+}
+
+// readWord reads the word starting at runes[start] and returns it,
+// whether it was quoted and the position following it. When partial
+// is set, unterminated quotes and JSON literals end with runes.
+func readWord(runes []rune, start int, partial bool) (string, bool, int, error) {
+	var word strings.Builder
+	quoted := false
+	i := start
+
+	if runes[i] == '{' || runes[i] == '[' {
+		end, err := jsonEnd(runes, i)
+		if err != nil && partial {
+			end, err = len(runes), nil
+		}
+		if err != nil {
+			return "", false, 0, err
+		}
+
+		word.WriteString(string(runes[i:end]))
+		i = end
+	}
+
+	for i < len(runes) && !unicode.IsSpace(runes[i]) && !isOperator(runes[i]) {
+		switch r := runes[i]; r {
+		case '\'':
+			quoted = true
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 && partial {
+				word.WriteString(string(runes[i+1:]))
+				i = len(runes)
+				break
+			}
+			if end < 0 {
+				return "", false, 0, fmt.Errorf("shell: unterminated quote: %s", string(runes[i:]))
+			}
+
+			word.WriteString(string(runes[i+1 : end]))
+			i = end + 1
+		case '"':
+			quoted = true
+			i++
+			for {
+				if i == len(runes) && partial {
+					break
+				}
+				if i == len(runes) {
+					return "", false, 0, fmt.Errorf("shell: unterminated quote: %s", string(runes[start:]))
+				}
+
+				if runes[i] == '"' {
+					i++
+					break
+				}
+
+				if runes[i] == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+					i++
+				}
+
+				word.WriteRune(runes[i])
+				i++
+			}
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+			}
+
+			word.WriteRune(runes[i])
+			i++
+		default:
+			word.WriteRune(r)
+			i++
+		}
+	}
+
+	return word.String(), quoted, i, nil
+}
+
+// jsonEnd returns the position following the JSON object or array
+// starting at runes[start], skipping brackets in JSON strings
+func jsonEnd(runes []rune, start int) (int, error) {
+	depth := 0
+	inString := false
+
+	for i := start; i < len(runes); i++ {
+		r := runes[i]
+
+		if inString {
+			switch r {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch r {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i + 1, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("shell: unterminated JSON: %s", string(runes[start:]))
+}
+
+func indexRune(runes []rune, start int, r rune) int {
+	for i := start; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,49 @@
+package shell_test
+
+import (
+	"testing"
+
+	"github.com/sneakybueno/fli/shell"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		input string
+		words []string
+	}{
+		{"", nil},
+		{"   ", nil},
+		{"ls", []string{"ls"}},
+		{"cd  users/ ", []string{"cd", "users/"}},
+		{`find users name "Jane Doe"`, []string{"find", "users", "name", "Jane Doe"}},
+		{`find users name 'Jane "JD" Doe'`, []string{"find", "users", "name", `Jane "JD" Doe`}},
+		{`cat "users/\"bueno\"/a\\b\n"`, []string{"cat", `users/"bueno"/a\b\n`}},
+		{`cat users/Jane\ Doe`, []string{"cat", "users/Jane Doe"}},
+		{`set "users/a b"/name Jane`, []string{"set", "users/a b/name", "Jane"}},
+		{`set users {"name": "Jane Doe", "tags": ["a b", "}"]}`, []string{"set", "users", `{"name": "Jane Doe", "tags": ["a b", "}"]}`}},
+		{`set users [1, [2, 3]] 4`, []string{"set", "users", "[1, [2, 3]]", "4"}},
+		{`set names {"a": "\"}"}`, []string{"set", "names", `{"a": "\"}"}`}},
+		{"set emoji 🔥 '日本'", []string{"set", "emoji", "🔥", "日本"}},
+		{`set empty ""`, []string{"set", "empty", ""}},
+		{`ls users|grep "a | b">>out`, []string{"ls", "users", "|", "grep", "a | b", ">>", "out"}},
+	}
+
+	for _, test := range tests {
+		words, err := shell.SplitWords(test.input)
+		assert.NoError(t, err, test.input)
+		assert.Equal(t, test.words, words, test.input)
+	}
+}
+
+func TestSplitWordsErrors(t *testing.T) {
+	for _, input := range []string{
+		`find users name "Jane`,
+		`find users name 'Jane`,
+		`set users {"name": "Jane"`,
+		`set users ["a", "]`,
+	} {
+		_, err := shell.SplitWords(input)
+		assert.Error(t, err, input)
+	}
+}